@@ -0,0 +1,366 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * audiobackend.go -> talkkonnect audio capture and playback backends (openal, file and null)
+ */
+
+package talkkonnect
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jdiderik/go-openal/openal"
+	"github.com/jdiderik/gumble/gumble"
+)
+
+// openal playback buffers of 20ms each, more buffers add latency but ride out scheduling stalls
+const openalBuffers = 16
+
+// AudioBackend is where a Stream captures mic audio from and plays the mixed received audio to,
+// all audio is 16 bit mono pcm at the mumble sample rate
+type AudioBackend interface {
+	Name() string
+
+	OpenCapture(frameSize int) error
+	StartCapture()
+	// ReadFrame returns the next frameSize samples, or nil when a full frame is not available yet
+	ReadFrame(frameSize int) []int16
+	StopCapture()
+	CloseCapture()
+
+	OpenPlayback() error
+	// Play queues pcm for playback, it returns false when the output has no room for it
+	Play(pcm []int16) bool
+	ClosePlayback()
+}
+
+// newAudioBackend returns the backend selected in the audio section of talkkonnect.xml
+func newAudioBackend() (AudioBackend, error) {
+	switch AudioBackendName {
+	case "", "openal":
+		return &openalBackend{captureDevice: AudioCaptureDevice, playbackDevice: AudioPlaybackDevice}, nil
+	case "file":
+		return &fileBackend{captureFile: AudioCaptureFile, playbackFile: AudioPlaybackFile}, nil
+	case "null":
+		return &nullBackend{}, nil
+	}
+	return nil, fmt.Errorf("Unknown Audio Backend %q, Use openal, file or null", AudioBackendName)
+}
+
+// openalBackend uses openal capture and playback devices, an empty device name is the system default,
+// alsa and pulseaudio devices are reached through their openal device names
+type openalBackend struct {
+	captureDevice  string
+	playbackDevice string
+	frameSize      int
+
+	capture   *openal.CaptureDevice
+	device    *openal.Device
+	context   *openal.Context
+	source    openal.Source
+	emptyBufs openal.Buffers
+	raw       []byte
+}
+
+func (o *openalBackend) Name() string {
+	return "openal"
+}
+
+func (o *openalBackend) OpenCapture(frameSize int) error {
+	if o.capture != nil {
+		o.capture.CaptureCloseDevice()
+	}
+	o.frameSize = frameSize
+	o.capture = openal.CaptureOpenDevice(o.captureDevice, gumble.AudioSampleRate, openal.FormatMono16, uint32(frameSize))
+	if o.capture == nil {
+		return fmt.Errorf("Cannot Open OpenAL Capture Device %q", o.captureDevice)
+	}
+	return nil
+}
+
+func (o *openalBackend) StartCapture() {
+	o.capture.CaptureStart()
+}
+
+func (o *openalBackend) ReadFrame(frameSize int) []int16 {
+	buff := o.capture.CaptureSamples(uint32(frameSize))
+	if len(buff) != frameSize*2 {
+		return nil
+	}
+	pcm := make([]int16, frameSize)
+	for i := range pcm {
+		pcm[i] = int16(binary.LittleEndian.Uint16(buff[i*2 : (i+1)*2]))
+	}
+	return pcm
+}
+
+// StopCapture closes and reopens the device so audio captured after the end of a transmission
+// is not sent at the start of the next one
+func (o *openalBackend) StopCapture() {
+	o.capture.CaptureStop()
+	if err := o.OpenCapture(o.frameSize); err != nil {
+		log.Println("error: ", err)
+	}
+}
+
+func (o *openalBackend) CloseCapture() {
+	if o.capture != nil {
+		o.capture.CaptureCloseDevice()
+		o.capture = nil
+	}
+}
+
+func (o *openalBackend) OpenPlayback() error {
+	o.device = openal.OpenDevice(o.playbackDevice)
+	if o.device == nil {
+		return fmt.Errorf("Cannot Open OpenAL Playback Device %q", o.playbackDevice)
+	}
+	o.context = o.device.CreateContext()
+	o.context.Activate()
+	o.source = openal.NewSource()
+	o.emptyBufs = openal.NewBuffers(openalBuffers)
+	return nil
+}
+
+func (o *openalBackend) Play(pcm []int16) bool {
+	if n := o.source.BuffersProcessed(); n > 0 {
+		reclaimedBufs := make(openal.Buffers, n)
+		o.source.UnqueueBuffers(reclaimedBufs)
+		o.emptyBufs = append(o.emptyBufs, reclaimedBufs...)
+	}
+
+	if len(o.emptyBufs) == 0 {
+		return false
+	}
+
+	if cap(o.raw) < len(pcm)*2 {
+		o.raw = make([]byte, len(pcm)*2)
+	}
+	raw := o.raw[:len(pcm)*2]
+	for i, value := range pcm {
+		binary.LittleEndian.PutUint16(raw[i*2:], uint16(value))
+	}
+
+	last := len(o.emptyBufs) - 1
+	buffer := o.emptyBufs[last]
+	o.emptyBufs = o.emptyBufs[:last]
+	buffer.SetData(openal.FormatMono16, raw, gumble.AudioSampleRate)
+	o.source.QueueBuffer(buffer)
+	if o.source.State() != openal.Playing {
+		o.source.Play()
+	}
+	return true
+}
+
+func (o *openalBackend) ClosePlayback() {
+	if o.device == nil {
+		return
+	}
+	o.source.Stop()
+	if n := o.source.BuffersQueued(); n > 0 {
+		queued := make(openal.Buffers, n)
+		o.source.UnqueueBuffers(queued)
+		queued.Delete()
+	}
+	o.emptyBufs.Delete()
+	o.source.Delete()
+	o.context.Destroy()
+	o.device.CloseDevice()
+	o.context = nil
+	o.device = nil
+}
+
+// fileBackend transmits a wav or raw pcm file in a loop and writes received audio to a wav or raw
+// file, either side is silent when no file is configured
+type fileBackend struct {
+	captureFile  string
+	playbackFile string
+
+	pcm       []int16
+	position  int
+	capturing bool
+
+	wav *wavWriter
+	out *os.File
+}
+
+func (f *fileBackend) Name() string {
+	return "file"
+}
+
+func (f *fileBackend) OpenCapture(frameSize int) error {
+	if f.captureFile == "" || f.pcm != nil {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(f.captureFile)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(strings.ToLower(f.captureFile), ".wav") {
+		if data, err = wavData(data); err != nil {
+			return fmt.Errorf("%s %v", f.captureFile, err)
+		}
+	}
+
+	f.pcm = make([]int16, len(data)/2)
+	for i := range f.pcm {
+		f.pcm[i] = int16(binary.LittleEndian.Uint16(data[i*2:]))
+	}
+	log.Printf("info: Audio Capture From File %s (%d Samples)\n", f.captureFile, len(f.pcm))
+	return nil
+}
+
+func (f *fileBackend) StartCapture() {
+	f.capturing = true
+}
+
+func (f *fileBackend) ReadFrame(frameSize int) []int16 {
+	if !f.capturing || len(f.pcm) == 0 {
+		return nil
+	}
+	frame := make([]int16, frameSize)
+	for i := range frame {
+		frame[i] = f.pcm[f.position]
+		f.position = (f.position + 1) % len(f.pcm)
+	}
+	return frame
+}
+
+func (f *fileBackend) StopCapture() {
+	f.capturing = false
+}
+
+func (f *fileBackend) CloseCapture() {
+	f.capturing = false
+	f.pcm = nil
+	f.position = 0
+}
+
+func (f *fileBackend) OpenPlayback() error {
+	if f.playbackFile == "" {
+		return nil
+	}
+
+	var err error
+	if strings.HasSuffix(strings.ToLower(f.playbackFile), ".wav") {
+		f.wav, err = newWavWriter(f.playbackFile)
+	} else {
+		f.out, err = os.Create(f.playbackFile)
+	}
+	if err != nil {
+		return err
+	}
+	log.Println("info: Audio Playback To File ", f.playbackFile)
+	return nil
+}
+
+func (f *fileBackend) Play(pcm []int16) bool {
+	var err error
+	switch {
+	case f.wav != nil:
+		err = f.wav.write(pcm)
+	case f.out != nil:
+		err = binary.Write(f.out, binary.LittleEndian, pcm)
+	}
+	if err != nil {
+		log.Println("error: Cannot Write Audio Playback File ", err)
+	}
+	return true
+}
+
+func (f *fileBackend) ClosePlayback() {
+	if f.wav != nil {
+		if err := f.wav.close(); err != nil {
+			log.Println("error: Cannot Close Audio Playback File ", err)
+		}
+		f.wav = nil
+	}
+	if f.out != nil {
+		f.out.Close()
+		f.out = nil
+	}
+}
+
+// wavData returns the sample data of a 16 bit mono wav file at the mumble sample rate
+func wavData(data []byte) ([]byte, error) {
+	if len(data) < 12 || !bytes.Equal(data[0:4], []byte("RIFF")) || !bytes.Equal(data[8:12], []byte("WAVE")) {
+		return nil, errors.New("is not a wav file")
+	}
+
+	var format bool
+	for offset := 12; offset+8 <= len(data); {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		body := data[offset+8:]
+		if size > len(body) {
+			size = len(body)
+		}
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, errors.New("has a short fmt chunk")
+			}
+			channels := binary.LittleEndian.Uint16(body[2:])
+			rate := binary.LittleEndian.Uint32(body[4:])
+			bits := binary.LittleEndian.Uint16(body[14:])
+			if channels != 1 || rate != gumble.AudioSampleRate || bits != 16 {
+				return nil, fmt.Errorf("must be 16 bit mono %dHz, found %d bit %d channel(s) %dHz", gumble.AudioSampleRate, bits, channels, rate)
+			}
+			format = true
+		case "data":
+			if !format {
+				return nil, errors.New("has no fmt chunk before the data chunk")
+			}
+			return body[:size], nil
+		}
+
+		offset += 8 + size + size%2
+	}
+	return nil, errors.New("has no data chunk")
+}
+
+// nullBackend captures nothing and discards everything played, for gateways without a sound card
+type nullBackend struct{}
+
+func (n *nullBackend) Name() string                    { return "null" }
+func (n *nullBackend) OpenCapture(frameSize int) error { return nil }
+func (n *nullBackend) StartCapture()                   {}
+func (n *nullBackend) ReadFrame(frameSize int) []int16 { return nil }
+func (n *nullBackend) StopCapture()                    {}
+func (n *nullBackend) CloseCapture()                   {}
+func (n *nullBackend) OpenPlayback() error             { return nil }
+func (n *nullBackend) Play(pcm []int16) bool           { return true }
+func (n *nullBackend) ClosePlayback()                  {}
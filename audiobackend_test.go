@@ -0,0 +1,165 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * audiobackend_test.go -> talkkonnect tests of the file and null audio backends without a sound card
+ */
+
+package talkkonnect
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jdiderik/gumble/gumble"
+)
+
+// 10ms of audio at the mumble sample rate
+const testFrameSize = gumble.AudioSampleRate / 100
+
+func testFrame(start int16) []int16 {
+	frame := make([]int16, testFrameSize)
+	for i := range frame {
+		frame[i] = start + int16(i)*7
+	}
+	return frame
+}
+
+// frames played to the file sink are written to the wav or raw file and come back unchanged when
+// the same file is the capture source
+func TestFileBackendPlaybackAndCapture(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{name: "wav", file: "received.wav"},
+		{name: "raw", file: "received.raw"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "talkkonnect")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, test.file)
+
+			first, second := testFrame(-1000), testFrame(2000)
+
+			sink := &fileBackend{playbackFile: path}
+			if err := sink.OpenPlayback(); err != nil {
+				t.Fatalf("OpenPlayback() error = %v", err)
+			}
+			for _, frame := range [][]int16{first, second} {
+				if !sink.Play(frame) {
+					t.Fatalf("Play() = false, want true")
+				}
+			}
+			sink.ClosePlayback()
+
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.name == "wav" {
+				if data, err = wavData(data); err != nil {
+					t.Fatalf("wavData() error = %v", err)
+				}
+			}
+			if len(data) != 2*2*testFrameSize {
+				t.Fatalf("%d bytes of audio written, want %d", len(data), 2*2*testFrameSize)
+			}
+			written := make([]int16, len(data)/2)
+			for i := range written {
+				written[i] = int16(binary.LittleEndian.Uint16(data[i*2:]))
+			}
+			if !reflect.DeepEqual(written, append(append([]int16{}, first...), second...)) {
+				t.Fatalf("written audio differs from the frames played")
+			}
+
+			source := &fileBackend{captureFile: path}
+			if err := source.OpenCapture(testFrameSize); err != nil {
+				t.Fatalf("OpenCapture() error = %v", err)
+			}
+			defer source.CloseCapture()
+
+			if frame := source.ReadFrame(testFrameSize); frame != nil {
+				t.Fatalf("ReadFrame() before StartCapture() = %d samples, want nil", len(frame))
+			}
+			source.StartCapture()
+			// the capture file is transmitted in a loop
+			for i, want := range [][]int16{first, second, first} {
+				if frame := source.ReadFrame(testFrameSize); !reflect.DeepEqual(frame, want) {
+					t.Fatalf("ReadFrame() %d differs from the frame played", i)
+				}
+			}
+			source.StopCapture()
+			if frame := source.ReadFrame(testFrameSize); frame != nil {
+				t.Fatalf("ReadFrame() after StopCapture() = %d samples, want nil", len(frame))
+			}
+		})
+	}
+}
+
+func TestNullBackend(t *testing.T) {
+	AudioBackendName = "null"
+	backend, err := newAudioBackend()
+	if err != nil {
+		t.Fatalf("newAudioBackend() error = %v", err)
+	}
+	if backend.Name() != "null" {
+		t.Fatalf("Name() = %q, want null", backend.Name())
+	}
+
+	if err := backend.OpenCapture(testFrameSize); err != nil {
+		t.Fatalf("OpenCapture() error = %v", err)
+	}
+	backend.StartCapture()
+	if frame := backend.ReadFrame(testFrameSize); frame != nil {
+		t.Errorf("ReadFrame() = %d samples, want nil", len(frame))
+	}
+	backend.StopCapture()
+	backend.CloseCapture()
+
+	if err := backend.OpenPlayback(); err != nil {
+		t.Fatalf("OpenPlayback() error = %v", err)
+	}
+	if !backend.Play(testFrame(0)) {
+		t.Errorf("Play() = false, want true")
+	}
+	backend.ClosePlayback()
+}
+
+func TestUnknownAudioBackend(t *testing.T) {
+	AudioBackendName = "alsa"
+	if _, err := newAudioBackend(); err == nil {
+		t.Fatalf("newAudioBackend() error = nil, want unknown backend error")
+	}
+}
@@ -0,0 +1,331 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * calls.go -> talkkonnect private and group calls, tx to users or channels through a mumble voice target
+ */
+
+package talkkonnect
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jdiderik/gumble/gumble"
+)
+
+// voice target 0 is normal talking to the channel and 31 the server loopback, 1-30 are whisper targets
+const callVoiceTargetID = 1
+
+// VoiceCall is a private call (one user) or group call (several users and/or channels)
+type VoiceCall struct {
+	Name     string             `json:"name"`
+	Users    []string           `json:"users,omitempty"`
+	Channels []VoiceCallChannel `json:"channels,omitempty"`
+}
+
+// VoiceCallChannel is a channel called, optionally with all of its subchannels
+type VoiceCallChannel struct {
+	Name        string `json:"name"`
+	SubChannels bool   `json:"subchannels,omitempty"`
+}
+
+type callStatus struct {
+	Active          bool        `json:"active"`
+	Call            *VoiceCall  `json:"call,omitempty"`
+	IdleTimeoutSecs int         `json:"idletimeoutsecs"`
+	Calls           []VoiceCall `json:"calls"`
+}
+
+var (
+	callMutex        sync.Mutex
+	activeCall       *VoiceCall
+	callChannelIDs   map[uint32]bool
+	callTimer        *time.Timer
+	callLastActivity time.Time
+)
+
+// kind returns private for a call to a single user and group for everything else
+func (c VoiceCall) kind() string {
+	if len(c.Users) == 1 && len(c.Channels) == 0 {
+		return "private"
+	}
+	return "group"
+}
+
+// callTarget returns the name of the call in progress, "" when tx goes to the channel
+func callTarget() string {
+	callMutex.Lock()
+	defer callMutex.Unlock()
+	if activeCall == nil {
+		return ""
+	}
+	return activeCall.Name
+}
+
+func getCallStatus() callStatus {
+	callMutex.Lock()
+	defer callMutex.Unlock()
+
+	status := callStatus{Active: activeCall != nil, IdleTimeoutSecs: CallIdleTimeoutSecs, Calls: Calls}
+	if activeCall != nil {
+		call := *activeCall
+		status.Call = &call
+	}
+	if status.Calls == nil {
+		status.Calls = []VoiceCall{}
+	}
+	return status
+}
+
+// parseCall turns a call request into a call, the request is the name of a call from the calls
+// section or user:name[,name...], channel:name or subchannels:name for a call not configured
+func parseCall(request string) (VoiceCall, error) {
+	request = strings.TrimSpace(request)
+	if request == "" {
+		return VoiceCall{}, errors.New("Call Needs a Call Name, user:name, channel:name or subchannels:name")
+	}
+
+	if i := strings.Index(request, ":"); i >= 0 {
+		kind, names := strings.ToLower(request[:i]), strings.TrimSpace(request[i+1:])
+		if names == "" {
+			return VoiceCall{}, fmt.Errorf("Call %q Needs a Name After the Colon", request)
+		}
+		switch kind {
+		case "user":
+			var users []string
+			for _, user := range strings.Split(names, ",") {
+				if user = strings.TrimSpace(user); user != "" {
+					users = append(users, user)
+				}
+			}
+			return VoiceCall{Name: strings.Join(users, ", "), Users: users}, nil
+		case "channel":
+			return VoiceCall{Name: names, Channels: []VoiceCallChannel{{Name: names}}}, nil
+		case "subchannels":
+			return VoiceCall{Name: names, Channels: []VoiceCallChannel{{Name: names, SubChannels: true}}}, nil
+		}
+	}
+
+	for _, call := range Calls {
+		if strings.EqualFold(call.Name, request) {
+			return call, nil
+		}
+	}
+	return VoiceCall{}, fmt.Errorf("Call %q Not Defined in XML config", request)
+}
+
+// startCall registers a voice target for the call with the server, from then on tx goes to the
+// call only until endCall
+func (b *Talkkonnect) startCall(call VoiceCall) error {
+	if !IsConnected {
+		return errors.New("Not Connected to a Server")
+	}
+
+	target := &gumble.VoiceTarget{ID: callVoiceTargetID}
+	channelIDs := make(map[uint32]bool)
+	var reached int
+	for _, name := range call.Users {
+		user := b.Client.Users.Find(name)
+		if user == nil {
+			log.Println("warn: Call User Not Online ", name)
+			continue
+		}
+		if user == b.Client.Self {
+			continue
+		}
+		target.AddUser(user)
+		reached++
+	}
+	for _, channel := range call.Channels {
+		found := b.findChannel(channel.Name)
+		if found == nil {
+			log.Println("warn: Call Channel Not Found ", channel.Name)
+			continue
+		}
+		target.AddChannel(found, channel.SubChannels, false, "")
+		channelIDs[found.ID] = true
+		if channel.SubChannels {
+			for _, child := range channelTree(found) {
+				channelIDs[child.ID] = true
+			}
+		}
+		reached++
+	}
+	if reached == 0 {
+		return fmt.Errorf("Nobody to Call in %s, None of its Users or Channels Found", call.Name)
+	}
+
+	// never let the rest of an over to the channel go to the call or the other way round
+	if b.IsTransmitting {
+		b.TransmitStop(false)
+	}
+
+	b.Client.Send(target)
+	b.Client.VoiceTarget = target
+
+	callMutex.Lock()
+	if callTimer != nil {
+		callTimer.Stop()
+		callTimer = nil
+	}
+	started := &call
+	activeCall = started
+	callChannelIDs = channelIDs
+	callLastActivity = time.Now()
+	if CallIdleTimeoutSecs > 0 {
+		callTimer = time.AfterFunc(time.Duration(CallIdleTimeoutSecs)*time.Second, func() {
+			b.callIdle(started)
+		})
+	}
+	callMutex.Unlock()
+
+	log.Printf("info: %s Call Started to %s, TX Now Goes to the Call Only\n", strings.Title(call.kind()), call.Name)
+	publishEvent(EventCallStart, eventCallData{Call: call.Name, Kind: call.kind(), Users: call.Users, Channels: callChannelNames(call)})
+	announce(fmt.Sprintf("%s call %s", call.kind(), call.Name))
+	return nil
+}
+
+// endCall unregisters the voice target of the call with the server and returns tx to the channel we are in
+func (b *Talkkonnect) endCall(reason string) {
+	callMutex.Lock()
+	call := activeCall
+	activeCall = nil
+	callChannelIDs = nil
+	if callTimer != nil {
+		callTimer.Stop()
+		callTimer = nil
+	}
+	callMutex.Unlock()
+
+	if call == nil {
+		return
+	}
+
+	if b.IsTransmitting {
+		b.TransmitStop(false)
+	}
+	if b.Client != nil {
+		// an empty voice target makes the server drop the whisper target of the call
+		if IsConnected {
+			b.Client.Send(&gumble.VoiceTarget{ID: callVoiceTargetID})
+		}
+		b.Client.VoiceTarget = nil
+	}
+
+	log.Printf("info: %s Call to %s Ended (%s), TX Back to Channel\n", strings.Title(call.kind()), call.Name, reason)
+	publishEvent(EventCallEnd, eventCallData{Call: call.Name, Kind: call.kind(), Users: call.Users, Channels: callChannelNames(*call), Reason: reason})
+	if IsConnected {
+		announce("call ended")
+	}
+}
+
+// nextCall steps through the calls of the calls section, after the last one tx returns to the channel
+func (b *Talkkonnect) nextCall() error {
+	if len(Calls) == 0 {
+		return errors.New("No Calls Defined in the calls Section of XML config")
+	}
+
+	current := callTarget()
+	next := 0
+	if current != "" {
+		next = len(Calls)
+		for i, call := range Calls {
+			if call.Name == current {
+				next = i + 1
+				break
+			}
+		}
+	}
+
+	if next >= len(Calls) {
+		b.endCall("ended")
+		return nil
+	}
+	return b.startCall(Calls[next])
+}
+
+// callActivity restarts the idle time out, called when we stop transmitting and when someone
+// in the call talks
+func callActivity() {
+	callMutex.Lock()
+	callLastActivity = time.Now()
+	callMutex.Unlock()
+}
+
+// callTalker restarts the idle time out when user is one of the parties of the call in progress
+func callTalker(user *gumble.User) {
+	callMutex.Lock()
+	defer callMutex.Unlock()
+	if activeCall == nil || user == nil {
+		return
+	}
+
+	if callChannelIDs[user.Channel.ID] {
+		callLastActivity = time.Now()
+		return
+	}
+	for _, name := range activeCall.Users {
+		if name == user.Name {
+			callLastActivity = time.Now()
+			return
+		}
+	}
+}
+
+// callIdle ends call once nobody in it talked and we did not transmit for the idle time out
+func (b *Talkkonnect) callIdle(call *VoiceCall) {
+	idle := time.Duration(CallIdleTimeoutSecs) * time.Second
+
+	callMutex.Lock()
+	if activeCall != call || callTimer == nil {
+		callMutex.Unlock()
+		return
+	}
+	// an over in progress keeps the call open, its end restarts the idle time
+	if b.IsTransmitting {
+		callLastActivity = time.Now()
+	}
+	if remaining := idle - time.Since(callLastActivity); remaining > 0 {
+		callTimer.Reset(remaining)
+		callMutex.Unlock()
+		return
+	}
+	callMutex.Unlock()
+
+	b.endCall("timeout")
+}
+
+func callChannelNames(call VoiceCall) []string {
+	var names []string
+	for _, channel := range call.Channels {
+		names = append(names, channel.Name)
+	}
+	return names
+}
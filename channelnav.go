@@ -0,0 +1,228 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * channelnav.go -> talkkonnect channel up/down over the channel tree in server display order
+ */
+
+package talkkonnect
+
+import (
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jdiderik/gumble/gumble"
+)
+
+// a move in progress, kept so a move refused by the server continues to the next channel
+// in the same direction instead of leaving the user where they were
+var (
+	channelNavMutex    sync.Mutex
+	channelNavTarget   *gumble.Channel
+	channelNavStep     int
+	channelNavAttempts int
+)
+
+// channelTree returns every channel depth first from the root, siblings ordered by position
+// then name the same way mumble clients display them
+func channelTree(root *gumble.Channel) []*gumble.Channel {
+	if root == nil {
+		return nil
+	}
+
+	children := make([]*gumble.Channel, 0, len(root.Children))
+	for _, child := range root.Children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].Position != children[j].Position {
+			return children[i].Position < children[j].Position
+		}
+		if strings.ToLower(children[i].Name) != strings.ToLower(children[j].Name) {
+			return strings.ToLower(children[i].Name) < strings.ToLower(children[j].Name)
+		}
+		return children[i].ID < children[j].ID
+	})
+
+	channels := []*gumble.Channel{root}
+	for _, child := range children {
+		channels = append(channels, channelTree(child)...)
+	}
+	return channels
+}
+
+// channelNavAllowed applies the include and exclude patterns of the channelnavigation section,
+// patterns are matched against the channel name and its path from the root such as Ops/*
+func channelNavAllowed(channel *gumble.Channel) bool {
+	name, fullPath := channel.Name, channelPath(channel)
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+			if ok, _ := path.Match(pattern, fullPath); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(ChannelNavInclude) > 0 && !matches(ChannelNavInclude) {
+		return false
+	}
+	if matches(ChannelNavExclude) {
+		return false
+	}
+
+	if ChannelNavEnterableOnly {
+		// the permission is unknown until the server answered a query, such channels are tried
+		// and skipped by OnPermissionDenied if entering is refused
+		permission := channel.Permission()
+		if permission == nil {
+			channel.RequestPermission()
+		} else if !permission.Has(gumble.PermissionEnter) {
+			return false
+		}
+	}
+	return true
+}
+
+// channelNavList returns the channels channel up/down moves through in tree order
+func (b *Talkkonnect) channelNavList() []*gumble.Channel {
+	var channels []*gumble.Channel
+	for _, channel := range channelTree(b.Client.Channels[0]) {
+		if channelNavAllowed(channel) {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// channelNavNext returns the channel step (+1 or -1) away from channel in tree order, it
+// also works from a channel that is filtered out of the navigation list
+func (b *Talkkonnect) channelNavNext(from *gumble.Channel, step int) *gumble.Channel {
+	tree := channelTree(b.Client.Channels[0])
+	order := make(map[*gumble.Channel]int, len(tree))
+	for i, channel := range tree {
+		order[channel] = i
+	}
+
+	list := b.channelNavList()
+	if len(list) == 0 {
+		log.Println("warn: No Channels to Navigate, Check the channelnavigation Section in XML config")
+		return nil
+	}
+
+	current, ok := order[from]
+	if !ok {
+		current = -1
+	}
+
+	if step > 0 {
+		for _, channel := range list {
+			if order[channel] > current {
+				return channel
+			}
+		}
+		if ChannelNavWrapAround {
+			return list[0]
+		}
+		log.Println("warn: Can't Increment Channel Last Channel Reached")
+		return nil
+	}
+
+	for i := len(list) - 1; i >= 0; i-- {
+		if order[list[i]] < current {
+			return list[i]
+		}
+	}
+	if ChannelNavWrapAround {
+		return list[len(list)-1]
+	}
+	log.Println("warn: Can't Decrement Channel First Channel Reached")
+	return nil
+}
+
+// channelNavigate moves one channel up (+1) or down (-1)
+func (b *Talkkonnect) channelNavigate(step int) {
+	if !IsConnected {
+		return
+	}
+
+	next := b.channelNavNext(b.Client.Self.Channel, step)
+	if next == nil || next == b.Client.Self.Channel {
+		return
+	}
+
+	channelNavMutex.Lock()
+	channelNavTarget = next
+	channelNavStep = step
+	channelNavAttempts = 1
+	channelNavMutex.Unlock()
+
+	log.Println("info: Moving to Channel ", channelPath(next), " ID ", next.ID)
+	b.Client.Self.Move(next)
+	prevChannelID = next.ID
+}
+
+// channelNavDenied continues a channel up/down past a channel the server refused to let us enter,
+// it gives up once every channel in the list was tried
+func (b *Talkkonnect) channelNavDenied() {
+	channelNavMutex.Lock()
+	defer channelNavMutex.Unlock()
+
+	if channelNavTarget == nil {
+		return
+	}
+
+	denied := channelNavTarget
+	channelNavTarget = nil
+
+	if channelNavAttempts >= len(b.channelNavList()) {
+		log.Println("warn: No Channel in This Direction Can be Entered")
+		return
+	}
+
+	next := b.channelNavNext(denied, channelNavStep)
+	if next == nil || next == b.Client.Self.Channel {
+		return
+	}
+
+	log.Println("info: Channel ", channelPath(denied), " Refused, Trying ", channelPath(next))
+	channelNavTarget = next
+	channelNavAttempts++
+	b.Client.Self.Move(next)
+	prevChannelID = next.ID
+}
+
+// channelNavArrived ends the move in progress once the server moved us
+func channelNavArrived() {
+	channelNavMutex.Lock()
+	channelNavTarget = nil
+	channelNavMutex.Unlock()
+}
@@ -44,7 +44,6 @@ import (
 	// "github.com/jdiderik/volume-go"
 	"io"
 	"log"
-	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
@@ -131,30 +130,7 @@ func Init(file string, ServerIndex string) {
 		colog.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 	}
 
-	switch Loglevel {
-	case "trace":
-		colog.SetMinLevel(colog.LTrace)
-		log.Println("info: Loglevel Set to Trace")
-	case "debug":
-		colog.SetMinLevel(colog.LDebug)
-		log.Println("info: Loglevel Set to Debug")
-	case "info":
-		colog.SetMinLevel(colog.LInfo)
-		log.Println("info: Loglevel Set to Info")
-	case "warning":
-		colog.SetMinLevel(colog.LWarning)
-		log.Println("info: Loglevel Set to Warning")
-	case "error":
-		colog.SetMinLevel(colog.LError)
-		log.Println("info: Loglevel Set to Error")
-	case "alert":
-		colog.SetMinLevel(colog.LAlert)
-		log.Println("info: Loglevel Set to Alert")
-	default:
-		colog.SetMinLevel(colog.LInfo)
-		log.Println("info: Default Loglevel unset in XML config automatically loglevel to Info")
-	}
-
+	setLogLevel(Loglevel)
 
 	if APEnabled {
 		log.Println("info: Contacting http Provisioning Server Pls Wait")
@@ -169,20 +145,32 @@ func Init(file string, ServerIndex string) {
 		}
 	}
 
-	if NextServerIndex > 0 {
-		AccountIndex = NextServerIndex
-	} else {
+	// an explicit -serverindex wins over the state of the last run, which wins over nextserverindex
+	stateIndex, stateChannel, stateOK := restoreState()
+
+	if ServerIndex != "" && ServerIndex != "0" {
 		AccountIndex, err = strconv.Atoi(ServerIndex)
+		if err != nil {
+			FatalCleanUp("Invalid Server Index " + ServerIndex)
+		}
+		stateOK = false
+	} else if stateOK {
+		AccountIndex = stateIndex
+	} else {
+		AccountIndex = NextServerIndex
 	}
 
 	b := Talkkonnect{
-		Config:      gumble.NewConfig(),
-		Name:        Name[AccountIndex],
-		Address:     Server[AccountIndex],
-		Username:    Username[AccountIndex],
-		Ident:       Ident[AccountIndex],
-		ChannelName: Channel[AccountIndex],
-		Daemonize:   Daemonize,
+		Config:    gumble.NewConfig(),
+		Daemonize: Daemonize,
+	}
+
+	if err := b.loadAccount(AccountIndex); err != nil {
+		FatalCleanUp(err.Error())
+	}
+
+	if stateOK && stateChannel != "" {
+		b.ChannelName = stateChannel
 	}
 
 	if MQTTEnabled == true {
@@ -194,42 +182,34 @@ func Init(file string, ServerIndex string) {
 		log.Printf("info: MQTT Server Subscription Disabled in Config")
 	}
 
-	if len(b.Username) == 0 {
-		buf := make([]byte, 6)
-		_, err := rand.Read(buf)
-		if err != nil {
-			FatalCleanUp("Cannot Generate Random Number Error " + err.Error())
-		}
+	// the beacon waits for mqtt and the mqttbeacon section, both can be enabled by a reload
+	go b.mqttBeaconRoutine()
+	go b.mqttEventBridge()
 
-		buf[0] |= 2
-		b.Config.Username = fmt.Sprintf("talkkonnect-%02x%02x%02x%02x%02x%02x", buf[0], buf[1], buf[2], buf[3], buf[4], buf[5])
-	} else {
-		b.Config.Username = Username[AccountIndex]
-	}
-
-	b.Config.Password = Password[AccountIndex]
+	go b.healthMonitor()
 
-	if Insecure[AccountIndex] {
-		b.TLSConfig.InsecureSkipVerify = true
+	if GPSEnabled {
+		go gpsReader()
+		go b.gpsReporter()
 	}
-	if Certificate[AccountIndex] != "" {
-		cert, err := tls.LoadX509KeyPair(Certificate[AccountIndex], Certificate[AccountIndex])
-		if err != nil {
-			FatalCleanUp("Certificate Error " + err.Error())
-		}
-		b.TLSConfig.Certificates = append(b.TLSConfig.Certificates, cert)
+
+	if AudioRecordEnabled && AudioRecordOnStart {
+		startRecording(AudioRecordMode)
 	}
 
 	if APIEnabled && !HTTPServRunning {
-		go func() {
-			http.HandleFunc("/", b.httpAPI)
-
-			if err := http.ListenAndServe(":"+APIListenPort, nil); err != nil {
-				FatalCleanUp("Problem Starting HTTP API Server " + err.Error())
-			}
-		}()
+		go b.startHTTPAPI()
 	}
 
+	hups := make(chan os.Signal, 1)
+	signal.Notify(hups, syscall.SIGHUP)
+	go func() {
+		for range hups {
+			log.Println("info: SIGHUP Received Reloading XML Config")
+			b.cmdReloadConfig()
+		}
+	}()
+
 	b.ClientStart()
 	IsConnected = false
 
@@ -242,6 +222,78 @@ func Init(file string, ServerIndex string) {
 	os.Exit(exitStatus)
 }
 
+func setLogLevel(level string) {
+	switch level {
+	case "trace":
+		colog.SetMinLevel(colog.LTrace)
+		log.Println("info: Loglevel Set to Trace")
+	case "debug":
+		colog.SetMinLevel(colog.LDebug)
+		log.Println("info: Loglevel Set to Debug")
+	case "info":
+		colog.SetMinLevel(colog.LInfo)
+		log.Println("info: Loglevel Set to Info")
+	case "warning":
+		colog.SetMinLevel(colog.LWarning)
+		log.Println("info: Loglevel Set to Warning")
+	case "error":
+		colog.SetMinLevel(colog.LError)
+		log.Println("info: Loglevel Set to Error")
+	case "alert":
+		colog.SetMinLevel(colog.LAlert)
+		log.Println("info: Loglevel Set to Alert")
+	default:
+		colog.SetMinLevel(colog.LInfo)
+		log.Println("info: Default Loglevel unset in XML config automatically loglevel to Info")
+	}
+}
+
+// loadAccount copies the account at index from the account list into the client settings,
+// nothing is changed if the account cannot be loaded
+func (b *Talkkonnect) loadAccount(index int) error {
+	list := getAccounts()
+	if index < 0 || index >= len(list) {
+		return fmt.Errorf("Account Index %d Out of Range, %d Default Account(s) Found in XML config", index, len(list))
+	}
+	account := list[index]
+
+	username := account.Username
+	if len(username) == 0 {
+		buf := make([]byte, 6)
+		_, err := rand.Read(buf)
+		if err != nil {
+			return fmt.Errorf("Cannot Generate Random Number Error " + err.Error())
+		}
+
+		buf[0] |= 2
+		username = fmt.Sprintf("talkkonnect-%02x%02x%02x%02x%02x%02x", buf[0], buf[1], buf[2], buf[3], buf[4], buf[5])
+	}
+
+	var tlsConfig tls.Config
+	if account.Insecure {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if account.Certificate != "" {
+		cert, err := tls.LoadX509KeyPair(account.Certificate, account.Certificate)
+		if err != nil {
+			return fmt.Errorf("Certificate Error " + err.Error())
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+
+	AccountIndex = index
+	b.Name = account.Name
+	b.Address = account.Server
+	b.Username = account.Username
+	b.Ident = account.Ident
+	b.ChannelName = account.Channel
+	b.Config.Username = username
+	b.Config.Password = account.Password
+	b.TLSConfig = tlsConfig
+
+	return nil
+}
+
 func (b *Talkkonnect) ClientStart() {
 	f, err := os.OpenFile(LogFilenameAndPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 	log.Println("info: Trying to Open File ", LogFilenameAndPath)
@@ -307,8 +359,8 @@ keyPressListenerLoop:
 				b.cmdListOnlineUsers()
 			case term.KeyF11:
 				b.cmdPlayback()
-			// case term.KeyF12:
-			// 	b.cmdGPSPosition()
+			case term.KeyF12:
+				b.cmdGPSPosition()
 			case term.KeyCtrlC:
 				talkkonnectAcknowledgements("\u001b[44;1m") // add blue background to banner reference https://www.lihaoyi.com/post/BuildyourownCommandLinewithANSIescapecodes.html#background-colors
 				b.cmdQuitTalkkonnect()
@@ -316,28 +368,30 @@ keyPressListenerLoop:
 			// 	b.cmdDebugStacktrace()
 			// case term.KeyCtrlE:
 			// 	b.cmdSendEmail()
-			// case term.KeyCtrlF:
-			// 	b.cmdConnPreviousServer()
-			// case term.KeyCtrlI: // New. Audio Recording. Traffic
-			// 	b.cmdAudioTrafficRecord()
-			// case term.KeyCtrlJ: // New. Audio Recording. Mic
-			// 	b.cmdAudioMicRecord()
-			// case term.KeyCtrlK: // New/ Audio Recording. Combo
-			// 	b.cmdAudioMicTrafficRecord()
+			case term.KeyCtrlF:
+				b.cmdConnPreviousServer()
+			case term.KeyCtrlI: // New. Audio Recording. Traffic
+				b.cmdAudioTrafficRecord()
+			case term.KeyCtrlJ: // New. Audio Recording. Mic
+				b.cmdAudioMicRecord()
+			case term.KeyCtrlK: // New/ Audio Recording. Combo
+				b.cmdAudioMicTrafficRecord()
 			case term.KeyCtrlL:
 				b.cmdClearScreen()
 			case term.KeyCtrlO:
 				b.cmdPingServers()
-			// case term.KeyCtrlN:
-			// 	b.cmdConnNextServer()
-			// case term.KeyCtrlP:
-			// 	b.cmdPanicSimulation()
+			case term.KeyCtrlN:
+				b.cmdConnNextServer()
+			case term.KeyCtrlP:
+				b.cmdPanicSimulation()
 			// case term.KeyCtrlG:
 			// 	b.cmdPlayRepeaterTone()
 			// case term.KeyCtrlR:
 			// 	b.cmdRepeatTxLoop()
-			// case term.KeyCtrlS:
-			// 	b.cmdScanChannels()
+			case term.KeyCtrlS:
+				b.cmdScanChannels()
+			case term.KeyCtrlW:
+				b.cmdNextCall()
 			// case term.KeyCtrlT:
 			// 	b.cmdThanks()
 			// case term.KeyCtrlV:
@@ -347,7 +401,9 @@ keyPressListenerLoop:
 			// case term.KeyCtrlX:
 			// 	b.cmdDumpXMLConfig()
 			default:
-				if ev.Ch != 0 {
+				if ev.Ch >= '0' && ev.Ch <= '9' {
+					b.presetKeyDigit(ev.Ch)
+				} else if ev.Ch != 0 {
 					log.Println("error: Invalid Keypress ASCII ", ev.Ch, "Press <DEL> for Menu")
 				} else {
 					log.Println("error: Key Not Mapped, Press <DEL> for menu", ev.Ch)
@@ -30,6 +30,7 @@
 package talkkonnect
 
 import (
+	"errors"
 	"fmt"
 	"github.com/jdiderik/gumble/gumble"
 	"github.com/jdiderik/gumble/gumbleffmpeg"
@@ -38,9 +39,14 @@ import (
 	"net"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// accountMutex serializes account changes from the keyboard, api, mqtt and config reload
+var accountMutex sync.Mutex
+
 func FatalCleanUp(message string) {
 	term.Close()
 	fmt.Println(message)
@@ -51,50 +57,104 @@ func FatalCleanUp(message string) {
 
 func (b *Talkkonnect) CleanUp() {
 
+	mqttPublishOffline()
+	stopRecording()
 	term.Close()
 	fmt.Println("SIGHUP Termination of Program Requested by User...shutting down talkkonnect")
 	os.Exit(0)
 }
 
 func (b *Talkkonnect) Connect() {
+	if err := b.dial(); err != nil {
+		log.Printf("error: Connection Error %v  connecting to %v failed", err, b.Address)
+		if !ServerHop {
+			b.startReconnect(err.Error())
+		}
+	}
+}
+
+// dial connects the gumble client to the current account and opens the audio stream on success
+func (b *Talkkonnect) dial() error {
 	IsConnected = false
 	IsPlayStream = false
 	NowStreaming = false
 	KillHeartBeat = false
-	var err error
-
-	_, err = gumble.DialWithDialer(new(net.Dialer), b.Address, b.Config, &b.TLSConfig)
 
+	_, err := gumble.DialWithDialer(new(net.Dialer), b.Address, b.Config, &b.TLSConfig)
 	if err != nil {
-		log.Printf("error: Connection Error %v  connecting to %v failed, attempting again...", err, b.Address)
-		if !ServerHop {
-			log.Println("debug: In the Connect Function & Trying With Username ", Username)
-			log.Println("debug: DEBUG Serverhop  Not Set Reconnecting!!")
-			b.ReConnect()
-		}
-	} else {
-		b.OpenStream()
+		return err
 	}
+
+	b.OpenStream()
+	return nil
 }
 
-func (b *Talkkonnect) ReConnect() {
-	IsConnected = false
-	IsPlayStream = false
-	NowStreaming = false
+// connectAccount moves talkkonnect to the account at index without restarting the process,
+// the old client is disconnected and its stream destroyed before the new account is dialed
+func (b *Talkkonnect) connectAccount(index int) error {
+	accountMutex.Lock()
+	defer accountMutex.Unlock()
 
-	if b.Client != nil {
-		log.Println("info: Attempting Reconnection With Server")
+	return b.connectAccountLocked(index)
+}
+
+// connectAccountLocked is connectAccount for callers already holding accountMutex
+func (b *Talkkonnect) connectAccountLocked(index int) error {
+	oldAddress := b.Address
+
+	if err := b.loadAccount(index); err != nil {
+		log.Println("error: Cannot Load Account ", err)
+		return err
+	}
+
+	ServerHop = true
+
+	if b.IsTransmitting {
+		b.TransmitStop(false)
+	}
+
+	if IsConnected && b.Client != nil {
+		log.Println("info: Disconnecting From Server ", oldAddress)
 		b.Client.Disconnect()
 	}
+	IsConnected = false
 
-	if ConnectAttempts < 3 {
-		if !ServerHop {
-			ConnectAttempts++
-			b.Connect()
-		}
-	} else {
-		FatalCleanUp("Unable to Connect to mumble server, Giving Up!")
+	if b.Stream != nil {
+		b.Stream.Destroy()
+		b.Stream = nil
 	}
+
+	log.Printf("info: Connecting to Account %s [%d] at %s\n", b.Name, AccountIndex, b.Address)
+	ServerHop = false
+	b.Connect()
+
+	return nil
+}
+
+// hopServer moves step accounts forward (or backward when negative) through the default accounts,
+// wrapping around at either end of the list
+func (b *Talkkonnect) hopServer(step int) error {
+	count := len(getAccounts())
+	if count < 2 {
+		return errors.New("Only One Default Account in XML config, No Other Server to Connect to")
+	}
+
+	// the reconnect supervisor and health failover move AccountIndex too, read and update it in one step
+	accountMutex.Lock()
+	index := ((AccountIndex+step)%count + count) % count
+	err := b.connectAccountLocked(index)
+	accountMutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if !IsConnected {
+		return fmt.Errorf("Cannot Connect to Account %s at %s", b.Name, b.Address)
+	}
+
+	announce(fmt.Sprintf("Connected to %s", b.Name))
+	return nil
 }
 
 func (b *Talkkonnect) TransmitStart() {
@@ -102,7 +162,12 @@ func (b *Talkkonnect) TransmitStart() {
 		return
 	}
 
-	t := time.Now()
+	if remaining := txLockedOut(); remaining > 0 {
+		log.Printf("warn: TX Locked Out After Time Out, Try Again in %v\n", remaining.Round(time.Second))
+		return
+	}
+
+	TxStartTime = time.Now()
 
 	if IsPlayStream {
 		IsPlayStream = false
@@ -118,17 +183,47 @@ func (b *Talkkonnect) TransmitStart() {
 	}
 
 	b.Stream.StartSource()
+	b.txWatchdogStart()
 
+	publishEvent(EventTxStart, eventTxData{Channel: b.Client.Self.Channel.Name, Call: callTarget()})
 }
 
 func (b *Talkkonnect) TransmitStop(withBeep bool) {
+	// a transmission that lost its server must not time out and lock out tx on the dead link
+	b.txWatchdogStop()
+
 	if !(IsConnected) {
 		return
 	}
 
+	if b.IsTransmitting {
+		metricsCountTx(time.Since(TxStartTime))
+	}
+
 	b.IsTransmitting = false
+	voxKeyed = false
 	b.Stream.StopSource()
+	recordTxStop()
+	callActivity()
 
+	publishEvent(EventTxStop, eventTxData{Channel: b.Client.Self.Channel.Name, Call: callTarget(), DurationSeconds: time.Since(TxStartTime).Seconds()})
+}
+
+// setVolume sets the digital output volume of the mixer in percent, clamped to 0-100
+func (b *Talkkonnect) setVolume(volume int) {
+	if volume > 100 {
+		volume = 100
+		log.Println("warn: Volume Already at Maximum")
+	}
+	if volume < 0 {
+		volume = 0
+		log.Println("warn: Volume Already at Minimum")
+	}
+
+	OutputVolume = volume
+	log.Printf("info: Volume Level is at %d%%\n", OutputVolume)
+	b.saveState()
+	publishEvent(EventVolume, eventVolumeData{Volume: OutputVolume, Muted: OutputMuted})
 }
 
 func (b *Talkkonnect) ChangeChannel(ChannelName string) {
@@ -136,7 +231,7 @@ func (b *Talkkonnect) ChangeChannel(ChannelName string) {
 		return
 	}
 
-	channel := b.Client.Channels.Find(ChannelName)
+	channel := b.findChannel(ChannelName)
 	if channel != nil {
 
 		b.Client.Self.Move(channel)
@@ -149,6 +244,36 @@ func (b *Talkkonnect) ChangeChannel(ChannelName string) {
 	}
 }
 
+// findChannel finds a channel by name below the root channel, or by its path from the root
+// channel such as "Ops/Room 1", an empty name is the root channel
+func (b *Talkkonnect) findChannel(name string) *gumble.Channel {
+	if name == "" || name == "/" {
+		return b.Client.Channels[0]
+	}
+
+	if channel := b.Client.Channels.Find(name); channel != nil {
+		return channel
+	}
+
+	names := strings.Split(strings.Trim(name, "/"), "/")
+	if root := b.Client.Channels[0]; root != nil && names[0] == root.Name {
+		if len(names) == 1 {
+			return root
+		}
+		names = names[1:]
+	}
+	return b.Client.Channels.Find(names...)
+}
+
+// channelPath returns the path of a channel from the root channel, the root channel itself is ""
+func channelPath(channel *gumble.Channel) string {
+	var names []string
+	for ; channel != nil && channel.Parent != nil; channel = channel.Parent {
+		names = append([]string{channel.Name}, names...)
+	}
+	return strings.Join(names, "/")
+}
+
 func (b *Talkkonnect) ParticipantLEDUpdate(verbose bool) {
 	if !(IsConnected) {
 		return
@@ -248,10 +373,6 @@ func (b *Talkkonnect) ChannelUp() {
 		return
 	}
 
-	if prevChannelID == 0 {
-		prevChannelID = b.Client.Self.Channel.ID
-	}
-
 	if TTSEnabled && TTSChannelUp {
 		err := playWavLocal(TTSChannelUpFilenameAndPath, TTSVolumeLevel)
 		if err != nil {
@@ -262,32 +383,7 @@ func (b *Talkkonnect) ChannelUp() {
 
 	prevButtonPress = "ChannelUp"
 
-	b.ListChannels(false)
-
-	// Set Upper Boundary
-	if b.Client.Self.Channel.ID == maxchannelid {
-		log.Println("error: Can't Increment Channel Maximum Channel Reached")
-		return
-	}
-
-	// Implement Seek Up Avoiding any null channels
-	if prevChannelID < maxchannelid {
-
-		prevChannelID++
-
-		for i := prevChannelID; uint32(i) < maxchannelid+1; i++ {
-
-			channel := b.Client.Channels[i]
-
-			if channel != nil {
-				b.Client.Self.Move(channel)
-				//displaychannel
-				time.Sleep(500 * time.Millisecond)
-				break
-			}
-		}
-	}
-	return
+	b.channelNavigate(1)
 }
 
 func (b *Talkkonnect) ChannelDown() {
@@ -295,119 +391,44 @@ func (b *Talkkonnect) ChannelDown() {
 		return
 	}
 
-	if prevChannelID == 0 {
-		prevChannelID = b.Client.Self.Channel.ID
-	}
-
 	prevButtonPress = "ChannelDown"
-	b.ListChannels(false)
-
-	// Set Lower Boundary
-	if int(b.Client.Self.Channel.ID) == 0 {
-		log.Println("error: Can't Decrement Channel Root Channel Reached")
-		channel := b.Client.Channels[uint32(AccountIndex)]
-		b.Client.Self.Move(channel)
-		//displaychannel
-		time.Sleep(500 * time.Millisecond)
-		
-		return
-	}
-
-	// Implement Seek Down Avoiding any null channels
-	if int(prevChannelID) > 0 {
 
-		prevChannelID--
-
-		for i := uint32(prevChannelID); uint32(i) < maxchannelid; i-- {
-			channel := b.Client.Channels[i]
-			if channel != nil {
-				b.Client.Self.Move(channel)
-				//displaychannel
-				time.Sleep(500 * time.Millisecond)
-				break
-			}
-		}
-	}
-	return
+	b.channelNavigate(-1)
 }
 
-func (b *Talkkonnect) Scan() {
+func (b *Talkkonnect) SendMessage(textmessage string, PRecursive bool) {
 	if !(IsConnected) {
 		return
 	}
 
-	b.ListChannels(false)
-
-	if b.Client.Self.Channel.ID+1 > maxchannelid {
-		prevChannelID = 0
-		channel := b.Client.Channels[prevChannelID]
-		b.Client.Self.Move(channel)
-		return
+	to := MessageToChannel
+	if PRecursive {
+		to = MessageToTree
 	}
-
-	if prevChannelID < maxchannelid {
-		prevChannelID++
-
-		for i := prevChannelID; uint32(i) < maxchannelid+1; i++ {
-			channel := b.Client.Channels[i]
-			if channel != nil {
-				b.Client.Self.Move(channel)
-				time.Sleep(1000 * time.Millisecond)
-				if len(b.Client.Self.Channel.Users) == 1 {
-					b.Scan()
-					break
-				} else {
-
-					log.Println("info: Found Someone Online Stopped Scan on Channel ", b.Client.Self.Channel.Name)
-					return
-				}
-			}
-		}
+	if _, err := b.sendTextMessage(to, "", textmessage); err != nil {
+		log.Println("error: Cannot Send Message ", err)
 	}
-	return
-}
-
-func (b *Talkkonnect) SendMessage(textmessage string, PRecursive bool) {
-	if !(IsConnected) {
-		return
-	}
-	b.Client.Self.Channel.Send(textmessage, PRecursive)
 }
 
 func (b *Talkkonnect) SetComment(comment string) {
 	if IsConnected {
 		b.Client.Self.SetComment(comment)
-		t := time.Now()
-	}
-}
-
-func (b *Talkkonnect) TxLockTimer() {
-	if PTxLockEnabled {
-		TxLockTicker := time.NewTicker(time.Duration(PTxlockTimeOutSecs) * time.Second)
-		log.Println("info: TX Locked for ", PTxlockTimeOutSecs, " seconds")
-		b.TransmitStop(false)
-		b.TransmitStart()
-
-		go func() {
-			<-TxLockTicker.C
-			b.TransmitStop(true)
-			log.Println("info: TX UnLocked After ", PTxlockTimeOutSecs, " seconds")
-		}()
 	}
 }
 
 func (b *Talkkonnect) pingServers() {
 	currentconn := " Not Connected "
-	for i := 0; i < len(Server); i++ {
-		resp, err := gumble.Ping(Server[i], time.Second*1, time.Second*5)
+	list := getAccounts()
+	for i := 0; i < len(list); i++ {
+		resp, err := gumble.Ping(list[i].Server, time.Second*1, time.Second*5)
 
-		if b.Address == Server[i] {
+		if b.Address == list[i].Server {
 			currentconn = " ** Connected ** "
 		} else {
 			currentconn = ""
 		}
 
-		log.Println("info: Server # ", i+1, "["+Name[i]+"]"+currentconn)
+		log.Println("info: Server # ", i+1, "["+list[i].Name+"]"+currentconn)
 
 		if err != nil {
 			log.Println(fmt.Sprintf("error: Ping Error ", err))
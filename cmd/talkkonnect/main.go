@@ -43,6 +43,7 @@ import (
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile `file`")
 var memprofile = flag.String("memprofile", "", "write memory profile to `file`")
 var serverindex = flag.String("serverindex", "0", "jump to server index [n]")
+var validate = flag.Bool("validate", false, "validate talkkonnect.xml configuration file and exit")
 
 
 func main() {
@@ -52,6 +53,19 @@ func main() {
 	flag.Usage = talkkonnectusage
 	flag.Parse()
 
+	if *validate {
+		problems := talkkonnect.ValidateConfig(*config)
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) > 0 {
+			fmt.Printf("%v: %d problem(s) found\n", *config, len(problems))
+			os.Exit(1)
+		}
+		fmt.Printf("%v: configuration is valid\n", *config)
+		os.Exit(0)
+	}
+
   if *cpuprofile != "" {
         f, err := os.Create(*cpuprofile)
         if err != nil {
@@ -89,6 +103,7 @@ func talkkonnectusage() {
 	fmt.Println("---------------------------------------------------------------------------------------")
 	fmt.Println("-config=~/go/src/github.com/jdiderik/talkkonnect/talkkonnect.xml")
 	fmt.Println("-serverindex=[n] for the index of the enabled server to connect to in XML file")
+	fmt.Println("-validate to check the configuration file and exit, non zero exit code on problems")
 	fmt.Println("-version for the version")
 	fmt.Println("-help for this screen")
 }
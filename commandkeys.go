@@ -54,6 +54,57 @@ func (b *Talkkonnect) cmdChannelDown() {
 	b.ChannelDown()
 }
 
+func (b *Talkkonnect) cmdMuteUnmute(subCommand string) {
+	log.Println("debug: Mute/Unmute Speaker Requested")
+
+	switch subCommand {
+	case "toggle":
+		OutputMuted = !OutputMuted
+	case "mute":
+		OutputMuted = true
+	case "unmute":
+		OutputMuted = false
+	}
+
+	if OutputMuted {
+		log.Println("info: Speaker Muted")
+	} else {
+		log.Println("info: Speaker UnMuted")
+	}
+
+	b.saveState()
+	publishEvent(EventVolume, eventVolumeData{Volume: OutputVolume, Muted: OutputMuted})
+}
+
+func (b *Talkkonnect) cmdVolume(level string) error {
+	log.Println("info: Volume ", level, " Requested")
+
+	volume, err := strconv.Atoi(level)
+	if err != nil || volume < 0 || volume > 100 {
+		log.Println("error: Volume Must be 0 to 100, Got ", level)
+		return fmt.Errorf("Volume Must be 0 to 100, Got %q", level)
+	}
+
+	b.setVolume(volume)
+	return nil
+}
+
+func (b *Talkkonnect) cmdChannel(name string) error {
+	log.Println("info: Channel ", name, " Requested")
+
+	if !IsConnected {
+		log.Println("error: Cannot Change Channel, Not Connected to a Server")
+		return fmt.Errorf("Not Connected to a Server")
+	}
+	if b.findChannel(name) == nil {
+		log.Println("error: Cannot Find Channel ", name)
+		return fmt.Errorf("Cannot Find Channel %q", name)
+	}
+
+	b.ChangeChannel(name)
+	return nil
+}
+
 func (b *Talkkonnect) cmdListServerChannels() {
 	log.Println("debug: F7 pressed Channel List Requested")
 	b.ListChannels(true)
@@ -140,35 +191,168 @@ func (b *Talkkonnect) cmdClearScreen() {
 	log.Println("debug: Ctrl-L Pressed Cleared Screen")
 }
 
+func (b *Talkkonnect) cmdReloadConfig() error {
+	log.Println("info: Reload XML Config Requested")
+	_, err := b.reloadConfig()
+	if err != nil {
+		log.Println("error: XML Config Reload Failed, Keeping Running Config ", err)
+	}
+	return err
+}
+
+func (b *Talkkonnect) cmdConnPreviousServer() error {
+	log.Println("debug: Ctrl-F Pressed")
+	log.Println("info: Connect to Previous Server Requested")
+
+	err := b.hopServer(-1)
+	if err != nil {
+		log.Println("error: Cannot Connect to Previous Server ", err)
+	}
+	return err
+}
+
+func (b *Talkkonnect) cmdConnNextServer() error {
+	log.Println("debug: Ctrl-N Pressed")
+	log.Println("info: Connect to Next Server Requested")
+
+	err := b.hopServer(1)
+	if err != nil {
+		log.Println("error: Cannot Connect to Next Server ", err)
+	}
+	return err
+}
+
+func (b *Talkkonnect) cmdPreset(number string) error {
+	log.Println("info: Preset ", number, " Requested")
+
+	preset, err := strconv.Atoi(number)
+	if err != nil || preset < 1 || preset > 99 {
+		log.Println("error: Preset Number Must be 1 to 99, Got ", number)
+		return fmt.Errorf("Preset Number Must be 1 to 99, Got %q", number)
+	}
+
+	if err := b.recallPreset(preset); err != nil {
+		log.Println("error: Cannot Recall Preset ", err)
+		return err
+	}
+	return nil
+}
+
+func (b *Talkkonnect) cmdCall(request string) error {
+	log.Println("info: Call ", request, " Requested")
+
+	call, err := parseCall(request)
+	if err == nil {
+		err = b.startCall(call)
+	}
+	if err != nil {
+		log.Println("error: Cannot Start Call ", err)
+	}
+	return err
+}
+
+func (b *Talkkonnect) cmdEndCall() {
+	log.Println("info: End Call Requested")
+	b.endCall("ended")
+}
+
+func (b *Talkkonnect) cmdNextCall() {
+	log.Println("debug: Ctrl-W Pressed")
+	log.Println("info: Next Private/Group Call Requested")
+
+	if err := b.nextCall(); err != nil {
+		log.Println("error: Cannot Start Call ", err)
+	}
+}
+
+func (b *Talkkonnect) cmdSendMessage(command string) error {
+	to, name, text := parseMessageCommand(command)
+	log.Printf("info: Send Message to %s %q Requested\n", to, name)
+
+	_, err := b.sendTextMessage(to, name, text)
+	if err != nil {
+		log.Println("error: Cannot Send Message ", err)
+	}
+	return err
+}
+
+func (b *Talkkonnect) cmdGPSPosition() {
+	log.Println("debug: F12 Pressed")
+	log.Println("info: GPS Position Requested")
+
+	if !GPSEnabled {
+		log.Println("warn: GPS Disabled in XML config")
+		return
+	}
+
+	fix := getGPSFix()
+	if !fix.Valid {
+		log.Printf("warn: GPS No Fix, Quality %d Satellites %d\n", fix.Quality, fix.Satellites)
+		if latitude, longitude, known := lastPosition(); known {
+			log.Printf("info: GPS Last Known Position %.6f,%.6f at %v\n", latitude, longitude, fix.Updated.Format(time.RFC3339))
+		}
+		return
+	}
+
+	log.Printf("info: GPS Date %s Time %s UTC\n", fix.Date, fix.Time)
+	log.Println("info: GPS", gpsPositionText(fix))
+}
+
+func (b *Talkkonnect) cmdScanChannels() {
+	log.Println("debug: Ctrl-S Pressed")
+	log.Println("info: Scan Channels Start/Stop Requested")
+	b.toggleScan()
+}
+
+func (b *Talkkonnect) cmdAudioTrafficRecord() {
+	log.Println("debug: Ctrl-I Pressed")
+	log.Println("info: Traffic Recording Start/Stop Requested")
+	toggleRecording("traffic")
+}
+
+func (b *Talkkonnect) cmdAudioMicRecord() {
+	log.Println("debug: Ctrl-J Pressed")
+	log.Println("info: Mic Recording Start/Stop Requested")
+	toggleRecording("mic")
+}
+
+func (b *Talkkonnect) cmdAudioMicTrafficRecord() {
+	log.Println("debug: Ctrl-K Pressed")
+	log.Println("info: Traffic & Mic Recording Start/Stop Requested")
+	toggleRecording("combo")
+}
+
 func (b *Talkkonnect) cmdPingServers() {
 	log.Println("debug: Ctrl-O Pressed")
 	log.Println("info: Ping Servers")
 	b.pingServers()
 }
 
-// func (b *Talkkonnect) cmdPanicSimulation() {
-// 	if !(IsConnected) {
-// 		return
-// 	}
-// 	log.Println("debug: Ctrl-P Pressed")
-// 	log.Println("info: Panic Button Start/Stop Simulation Requested")
-
-// 	if PEnabled {
-
-// 		if b.IsTransmitting {
-// 			b.TransmitStop(false)
-// 		} else {
-// 			b.IsTransmitting = true
-// 			b.SendMessage(PMessage, PRecursive)
-
-// 		}
+func (b *Talkkonnect) cmdPanicSimulation() error {
+	log.Println("debug: Ctrl-P Pressed")
+	log.Println("info: Panic Button Start/Stop Simulation Requested")
 
-// 		if PSendIdent {
-// 			b.SendMessage(fmt.Sprintf("My Username is %s and Ident is %s", b.Username, b.Ident), PRecursive)
-// 		}
+	err := b.togglePanic()
+	if err != nil {
+		log.Println("error: Cannot Start Panic ", err)
+	}
+	return err
+}
 
-// 		IsPlayStream = false
-// 		b.IsTransmitting = false
-		
-// 	}
-// }
+func (b *Talkkonnect) cmdPanic(state string) error {
+	log.Println("info: Panic ", state, " Requested")
+
+	switch state {
+	case "on":
+		if err := b.startPanic(); err != nil {
+			log.Println("error: Cannot Start Panic ", err)
+			return err
+		}
+	case "off":
+		b.cancelPanic()
+	default:
+		log.Println("error: Panic Must be on or off, Got ", state)
+		return fmt.Errorf("Panic Must be on or off, Got %q", state)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * configreload.go -> talkkonnect hot reload of talkkonnect.xml without restarting the process
+ */
+
+package talkkonnect
+
+import (
+	"errors"
+	"log"
+	"reflect"
+	"sync"
+)
+
+var reloadMutex sync.Mutex
+
+// configChanges describes what differed between the running config and the reloaded one
+type configChanges struct {
+	Accounts        bool     `json:"accounts"`
+	Sounds          bool     `json:"sounds"`
+	API             bool     `json:"api"`
+	MQTT            bool     `json:"mqtt"`
+	LogLevel        bool     `json:"loglevel"`
+	Monitor         bool     `json:"monitor"`
+	Reconnected     bool     `json:"reconnected"`
+	RestartRequired []string `json:"restartrequired,omitempty"`
+}
+
+func (b *Talkkonnect) reloadConfig() (configChanges, error) {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	var changes configChanges
+
+	document, err := loadxmlconfig(ConfigXMLFile)
+	if err != nil {
+		return changes, err
+	}
+
+	if countDefaultAccounts(document) == 0 {
+		return changes, errors.New("No Default Accounts Found in talkkonnect.xml File! Keeping Running Config")
+	}
+
+	running := RunningConfig
+	if running == nil {
+		running = &Document{}
+	}
+
+	oldSoftware := running.Global.Software
+	newSoftware := document.Global.Software
+
+	changes.Accounts = !reflect.DeepEqual(running.Accounts, document.Accounts)
+	changes.Sounds = !reflect.DeepEqual(oldSoftware.Sounds, newSoftware.Sounds)
+	changes.API = !reflect.DeepEqual(oldSoftware.API, newSoftware.API)
+	changes.MQTT = !reflect.DeepEqual(oldSoftware.MQTT, newSoftware.MQTT)
+	changes.LogLevel = oldSoftware.Settings.Loglevel != newSoftware.Settings.Loglevel
+	changes.Monitor = !reflect.DeepEqual(oldSoftware.Monitor, newSoftware.Monitor)
+
+	// remember what the running account looks like before the account list is replaced
+	var oldAccount *mumbleAccount
+	if oldAccounts, oldAccountIndex := getAccounts(), AccountIndex; oldAccountIndex < len(oldAccounts) {
+		oldAccount = &oldAccounts[oldAccountIndex]
+	}
+	oldMQTTTopic := MQTTTopic
+
+	applyxmlconfig(ConfigXMLFile, document)
+
+	if changes.LogLevel {
+		setLogLevel(Loglevel)
+	}
+
+	if changes.API {
+		if oldSoftware.API.Enabled != newSoftware.API.Enabled || oldSoftware.API.ListenPort != newSoftware.API.ListenPort {
+			changes.RestartRequired = append(changes.RestartRequired, "api enabled/apilistenport")
+		}
+		log.Println("info: API Permissions Reloaded")
+	}
+
+	oldGPS, newGPS := running.Global.Hardware.GPS, document.Global.Hardware.GPS
+	if oldGPS.Enabled != newGPS.Enabled || oldGPS.Port != newGPS.Port || oldGPS.File != newGPS.File || oldGPS.Baud != newGPS.Baud {
+		changes.RestartRequired = append(changes.RestartRequired, "gps enabled/port/file/baud")
+	}
+
+	if changes.Sounds {
+		log.Println("info: Sounds Reloaded")
+	}
+
+	if changes.MQTT {
+		b.mqttReload(running, document, oldMQTTTopic)
+	}
+
+	if changes.Accounts {
+		// follow the running account by name, its index may have moved in the xml file
+		newAccounts := getAccounts()
+		newIndex := 0
+		for i := range newAccounts {
+			if oldAccount != nil && newAccounts[i].Name == oldAccount.Name {
+				newIndex = i
+				break
+			}
+		}
+
+		if oldAccount != nil && newAccounts[newIndex] == *oldAccount {
+			accountMutex.Lock()
+			AccountIndex = newIndex
+			accountMutex.Unlock()
+			log.Println("info: Accounts Reloaded, Current Account Unchanged No Reconnect Needed")
+		} else {
+			log.Println("info: Current Account Changed in XML Config, Reconnecting")
+			if err := b.connectAccount(newIndex); err != nil {
+				return changes, err
+			}
+			changes.Reconnected = true
+		}
+	}
+
+	// a reconnect already listens to the new monitor channels
+	if changes.Monitor && !changes.Reconnected {
+		b.stopMonitor()
+		b.startMonitor()
+	}
+
+	log.Printf("info: XML Config Reloaded accounts=%v sounds=%v api=%v mqtt=%v loglevel=%v monitor=%v reconnected=%v\n", changes.Accounts, changes.Sounds, changes.API, changes.MQTT, changes.LogLevel, changes.Monitor, changes.Reconnected)
+	for _, item := range changes.RestartRequired {
+		log.Println("warn: Change Requires Restart of talkkonnect to Take Effect: ", item)
+	}
+
+	return changes, nil
+}
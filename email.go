@@ -0,0 +1,87 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * email.go -> talkkonnect email over smtp with the gps position for panic alerts
+ */
+
+package talkkonnect
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// sendEmail mails the subject and body to the receivers of the smtp section, the gps date/time,
+// position and google maps link are appended as configured
+func sendEmail(subject string, body string) error {
+	if !EmailEnabled {
+		return errors.New("Email Disabled in the smtp Section of XML config")
+	}
+	if EmailReceiver == "" {
+		return errors.New("No Email Receiver in the smtp Section of XML config")
+	}
+
+	host, _, err := net.SplitHostPort(EmailServer)
+	if err != nil {
+		return fmt.Errorf("Invalid SMTP Server %q %v", EmailServer, err)
+	}
+
+	latitude, longitude, known := lastPosition()
+	if EmailGpsDateTime && GPSDate != "" {
+		body += fmt.Sprintf("\r\nGPS Date/Time %s %s", GPSDate, GPSTime)
+	}
+	if EmailGpsLatLong && known {
+		body += fmt.Sprintf("\r\nLatitude %.6f Longitude %.6f", latitude, longitude)
+	}
+	if EmailGoogleMapsURL && known {
+		body += "\r\n" + googleMapsURL(latitude, longitude)
+	}
+
+	var receivers []string
+	for _, receiver := range strings.Split(EmailReceiver, ",") {
+		if receiver = strings.TrimSpace(receiver); receiver != "" {
+			receivers = append(receivers, receiver)
+		}
+	}
+
+	message := "From: " + EmailUsername + "\r\n" +
+		"To: " + strings.Join(receivers, ", ") + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body + "\r\n"
+
+	auth := smtp.PlainAuth("", EmailUsername, EmailPassword, host)
+	return smtp.SendMail(EmailServer, auth, EmailUsername, receivers, []byte(message))
+}
+
+func googleMapsURL(latitude float64, longitude float64) string {
+	return fmt.Sprintf("https://www.google.com/maps?q=%.6f,%.6f", latitude, longitude)
+}
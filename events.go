@@ -0,0 +1,218 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * events.go -> talkkonnect live event feed, fan out of typed events to api subscribers
+ */
+
+package talkkonnect
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// event types published on the live event feed
+const (
+	EventConnected        = "connected"
+	EventDisconnected     = "disconnected"
+	EventUserJoined       = "userjoined"
+	EventUserLeft         = "userleft"
+	EventUserMoved        = "usermoved"
+	EventTextMessage      = "textmessage"
+	EventTalkerStart      = "talkerstart"
+	EventTalkerStop       = "talkerstop"
+	EventTxStart          = "txstart"
+	EventTxStop           = "txstop"
+	EventTxTimeout        = "txtimeout"
+	EventPermissionDenied = "permissiondenied"
+	EventScanHold         = "scanhold"
+	EventCallStart        = "callstart"
+	EventCallEnd          = "callend"
+	EventPanic            = "panic"
+	EventVolume           = "volume"
+)
+
+// slow subscribers lose events rather than blocking the gumble event handlers
+const eventSubscriberBuffer = 64
+
+type tkEvent struct {
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+type eventServerData struct {
+	Account string `json:"account"`
+	Server  string `json:"server"`
+	Channel string `json:"channel,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type eventUserData struct {
+	User      string `json:"user"`
+	Session   uint32 `json:"session"`
+	Channel   string `json:"channel,omitempty"`
+	ChannelID uint32 `json:"channelid"`
+	Monitored bool   `json:"monitored,omitempty"`
+}
+
+type eventMessageData struct {
+	ID       uint64   `json:"id"`
+	Sender   string   `json:"sender"`
+	Channels []string `json:"channels,omitempty"`
+	Trees    []string `json:"trees,omitempty"`
+	Private  bool     `json:"private"`
+	Message  string   `json:"message"`
+}
+
+type eventTxData struct {
+	Channel         string  `json:"channel,omitempty"`
+	Call            string  `json:"call,omitempty"`
+	DurationSeconds float64 `json:"durationseconds,omitempty"`
+	LockoutSeconds  float64 `json:"lockoutseconds,omitempty"`
+}
+
+type eventCallData struct {
+	Call     string   `json:"call"`
+	Kind     string   `json:"kind"`
+	Users    []string `json:"users,omitempty"`
+	Channels []string `json:"channels,omitempty"`
+	Reason   string   `json:"reason,omitempty"`
+}
+
+type eventPanicData struct {
+	Active          bool    `json:"active"`
+	Channel         string  `json:"channel,omitempty"`
+	Latitude        float64 `json:"latitude,omitempty"`
+	Longitude       float64 `json:"longitude,omitempty"`
+	DurationSeconds float64 `json:"durationseconds,omitempty"`
+}
+
+type eventVolumeData struct {
+	Volume int  `json:"volume"`
+	Muted  bool `json:"muted"`
+}
+
+type eventPermissionData struct {
+	Reason  string `json:"reason"`
+	Channel string `json:"channel,omitempty"`
+}
+
+var (
+	eventSubscribers      = make(map[chan tkEvent]bool)
+	eventSubscribersMutex sync.Mutex
+)
+
+func subscribeEvents() chan tkEvent {
+	ch := make(chan tkEvent, eventSubscriberBuffer)
+	eventSubscribersMutex.Lock()
+	eventSubscribers[ch] = true
+	eventSubscribersMutex.Unlock()
+	return ch
+}
+
+func unsubscribeEvents(ch chan tkEvent) {
+	eventSubscribersMutex.Lock()
+	delete(eventSubscribers, ch)
+	eventSubscribersMutex.Unlock()
+}
+
+func publishEvent(eventType string, data interface{}) {
+	event := tkEvent{Type: eventType, Time: time.Now(), Data: data}
+
+	eventSubscribersMutex.Lock()
+	defer eventSubscribersMutex.Unlock()
+
+	for ch := range eventSubscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Println("warn: Event Subscriber Too Slow Dropped Event ", eventType)
+		}
+	}
+}
+
+// apiEvents streams events as server-sent events, ?types=txstart,txstop limits the feed to the listed types
+func (b *Talkkonnect) apiEvents(w http.ResponseWriter, r *http.Request) {
+	if !apiAllowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apiWriteError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	var wanted map[string]bool
+	if types := r.URL.Query().Get("types"); types != "" {
+		wanted = make(map[string]bool)
+		for _, t := range strings.Split(types, ",") {
+			wanted[strings.TrimSpace(t)] = true
+		}
+	}
+
+	events := subscribeEvents()
+	defer unsubscribeEvents(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	log.Println("info: Event Feed Subscriber Connected From ", r.RemoteAddr)
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Println("info: Event Feed Subscriber Disconnected From ", r.RemoteAddr)
+			return
+		case <-keepAlive.C:
+			fmt.Fprintf(w, ": keepalive\n\n")
+			flusher.Flush()
+		case event := <-events:
+			if wanted != nil && !wanted[event.Type] {
+				continue
+			}
+			payload, err := json.Marshal(event)
+			if err != nil {
+				log.Println("error: Cannot Encode Event ", event.Type, err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, payload)
+			flusher.Flush()
+		}
+	}
+}
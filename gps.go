@@ -0,0 +1,380 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * gps.go -> talkkonnect nmea gps receiver (serial port, pty or recorded file) and position reports
+ */
+
+package talkkonnect
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jdiderik/gumble/gumble"
+)
+
+const (
+	// a fix not refreshed for this long is considered lost
+	gpsStaleAfter = 10 * time.Second
+	// wait before reopening a gps device that went away
+	gpsRetryDelay    = 5 * time.Second
+	knotsToKmh       = 1.852
+	earthRadiusM     = 6371000
+	gpsReportModeOff = "none"
+)
+
+// GPSFix is the state of the gps receiver, built from the GGA and RMC sentences
+type GPSFix struct {
+	Valid      bool      `json:"valid"`
+	Quality    int       `json:"quality"`
+	Satellites int       `json:"satellites"`
+	HDOP       float64   `json:"hdop,omitempty"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	AltitudeM  float64   `json:"altitudem"`
+	SpeedKmh   float64   `json:"speedkmh"`
+	Course     float64   `json:"course"`
+	Date       string    `json:"date,omitempty"`
+	Time       string    `json:"time,omitempty"`
+	Updated    time.Time `json:"updated"`
+}
+
+var (
+	gpsMutex sync.Mutex
+	gpsFix   GPSFix
+)
+
+// getGPSFix returns the gps state, a fix older than gpsStaleAfter is reported as not valid
+func getGPSFix() GPSFix {
+	gpsMutex.Lock()
+	defer gpsMutex.Unlock()
+
+	fix := gpsFix
+	if fix.Valid && time.Since(fix.Updated) > gpsStaleAfter {
+		fix.Valid = false
+	}
+	return fix
+}
+
+// lastPosition returns the last position from the gps, known is false before the first fix, a
+// position is kept after the fix is lost as the last known one
+func lastPosition() (latitude float64, longitude float64, known bool) {
+	gpsMutex.Lock()
+	defer gpsMutex.Unlock()
+	return gpsFix.Latitude, gpsFix.Longitude, !gpsFix.Updated.IsZero()
+}
+
+// gpsReader feeds the nmea sentences of the gps port or file into gpsFix until talkkonnect exits
+func gpsReader() {
+	source := GPSPort
+	if GPSFile != "" {
+		source = GPSFile
+	}
+	log.Println("info: GPS Reading NMEA From ", source)
+
+	for {
+		if err := gpsReadSource(source); err != nil {
+			log.Println("error: GPS ", err)
+		}
+		time.Sleep(gpsRetryDelay)
+	}
+}
+
+func gpsReadSource(source string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	// a recorded log is replayed in a loop, anything else is a serial device or pty
+	replay := info.Mode().IsRegular()
+	if !replay && isCommandAvailable("stty") {
+		if output, err := exec.Command("stty", gpsSttyArgs(source)...).CombinedOutput(); err != nil {
+			log.Printf("warn: GPS Cannot Set Serial Port %s %v %s\n", source, err, strings.TrimSpace(string(output)))
+		}
+	}
+
+	for {
+		file, err := os.Open(source)
+		if err != nil {
+			return err
+		}
+
+		err = gpsReadSentences(file, replay)
+		file.Close()
+
+		if !replay || (err != nil && err != io.EOF) {
+			if err == nil || err == io.EOF {
+				return errors.New("Device " + source + " Closed")
+			}
+			return err
+		}
+	}
+}
+
+func gpsReadSentences(reader io.Reader, replay bool) error {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		if err := gpsSentence(scanner.Text()); err != nil {
+			log.Println("debug: GPS ", err)
+			continue
+		}
+		if replay && GPSReplayDelayMs > 0 {
+			time.Sleep(time.Duration(GPSReplayDelayMs) * time.Millisecond)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+func gpsSttyArgs(port string) []string {
+	args := []string{"-F", port, strconv.Itoa(GPSBaud), "raw", "-echo", fmt.Sprintf("cs%d", GPSDataBits)}
+	switch GPSParity {
+	case "even":
+		args = append(args, "parenb", "-parodd")
+	case "odd":
+		args = append(args, "parenb", "parodd")
+	default:
+		args = append(args, "-parenb")
+	}
+	if GPSStopBits == 2 {
+		args = append(args, "cstopb")
+	} else {
+		args = append(args, "-cstopb")
+	}
+	return args
+}
+
+// gpsSentence applies one nmea sentence to gpsFix, only GGA and RMC from any talker are used
+func gpsSentence(line string) error {
+	fields, err := parseNMEA(line)
+	if err != nil || len(fields[0]) < 3 {
+		return err
+	}
+
+	gpsMutex.Lock()
+	defer gpsMutex.Unlock()
+
+	// latitude, hemisphere, longitude, hemisphere start at field 2 in GGA and 3 in RMC
+	fix, position := gpsFix, 2
+	switch fields[0][len(fields[0])-3:] {
+	case "GGA":
+		if len(fields) < 10 {
+			return fmt.Errorf("Short GGA Sentence %q", line)
+		}
+		fix.Quality, _ = strconv.Atoi(fields[6])
+		fix.Satellites, _ = strconv.Atoi(fields[7])
+		fix.HDOP, _ = strconv.ParseFloat(fields[8], 64)
+		fix.Valid = fix.Quality > 0
+		if fix.Valid {
+			fix.AltitudeM, _ = strconv.ParseFloat(fields[9], 64)
+		}
+	case "RMC":
+		if len(fields) < 10 {
+			return fmt.Errorf("Short RMC Sentence %q", line)
+		}
+		fix.Valid, position = fields[2] == "A", 3
+		if fix.Valid {
+			if knots, err := strconv.ParseFloat(fields[7], 64); err == nil {
+				fix.SpeedKmh = knots * knotsToKmh
+			}
+			if course, err := strconv.ParseFloat(fields[8], 64); err == nil {
+				fix.Course = course
+			}
+			fix.Date = nmeaDate(fields[9])
+		}
+	default:
+		return nil
+	}
+
+	if !fix.Valid {
+		gpsFix.Valid = false
+		gpsFix.Quality, gpsFix.Satellites = fix.Quality, fix.Satellites
+		return nil
+	}
+
+	if fix.Latitude, err = nmeaCoordinate(fields[position], fields[position+1]); err != nil {
+		return err
+	}
+	if fix.Longitude, err = nmeaCoordinate(fields[position+2], fields[position+3]); err != nil {
+		return err
+	}
+	fix.Time = nmeaTime(fields[1])
+	fix.Updated = time.Now()
+	gpsFix = fix
+
+	// kept for the older code that reads the position from the globals
+	GPSLatitude, GPSLongitude = fix.Latitude, fix.Longitude
+	GPSTime, GPSDate = fix.Time, fix.Date
+	return nil
+}
+
+// parseNMEA checks the checksum of a sentence and returns its fields, the first field is the
+// talker and sentence type such as GPGGA
+func parseNMEA(line string) ([]string, error) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "$") {
+		return nil, fmt.Errorf("Not an NMEA Sentence %q", line)
+	}
+
+	body := line[1:]
+	if i := strings.LastIndex(body, "*"); i >= 0 {
+		sum, err := strconv.ParseUint(body[i+1:], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("Bad NMEA Checksum %q", line)
+		}
+		body = body[:i]
+
+		var calculated byte
+		for j := 0; j < len(body); j++ {
+			calculated ^= body[j]
+		}
+		if byte(sum) != calculated {
+			return nil, fmt.Errorf("NMEA Checksum Mismatch %q", line)
+		}
+	}
+	return strings.Split(body, ","), nil
+}
+
+// nmeaCoordinate converts ddmm.mmmm (dddmm.mmmm for longitude) and the hemisphere to degrees
+func nmeaCoordinate(value string, hemisphere string) (float64, error) {
+	dot := strings.Index(value, ".")
+	if dot < 0 {
+		dot = len(value)
+	}
+	if dot < 3 {
+		return 0, fmt.Errorf("Bad NMEA Coordinate %q", value)
+	}
+
+	degrees, err := strconv.Atoi(value[:dot-2])
+	if err != nil {
+		return 0, fmt.Errorf("Bad NMEA Coordinate %q", value)
+	}
+	minutes, err := strconv.ParseFloat(value[dot-2:], 64)
+	if err != nil {
+		return 0, fmt.Errorf("Bad NMEA Coordinate %q", value)
+	}
+
+	coordinate := float64(degrees) + minutes/60
+	if hemisphere == "S" || hemisphere == "W" {
+		coordinate = -coordinate
+	}
+	return coordinate, nil
+}
+
+// nmeaTime turns hhmmss.ss into hh:mm:ss utc
+func nmeaTime(value string) string {
+	if len(value) < 6 {
+		return ""
+	}
+	return value[0:2] + ":" + value[2:4] + ":" + value[4:6]
+}
+
+// nmeaDate turns ddmmyy into yyyy-mm-dd
+func nmeaDate(value string) string {
+	if len(value) != 6 {
+		return ""
+	}
+	return "20" + value[4:6] + "-" + value[2:4] + "-" + value[0:2]
+}
+
+// distanceMeters is the great circle distance between two positions
+func distanceMeters(latitude1 float64, longitude1 float64, latitude2 float64, longitude2 float64) float64 {
+	toRadians := math.Pi / 180
+	dLatitude := (latitude2 - latitude1) * toRadians
+	dLongitude := (longitude2 - longitude1) * toRadians
+	a := math.Sin(dLatitude/2)*math.Sin(dLatitude/2) +
+		math.Cos(latitude1*toRadians)*math.Cos(latitude2*toRadians)*math.Sin(dLongitude/2)*math.Sin(dLongitude/2)
+	return 2 * earthRadiusM * math.Asin(math.Sqrt(a))
+}
+
+func gpsPositionText(fix GPSFix) string {
+	return fmt.Sprintf("Position %.6f,%.6f Altitude %.0fm Speed %.0fkm/h Course %.0f Satellites %d %s",
+		fix.Latitude, fix.Longitude, fix.AltitudeM, fix.SpeedKmh, fix.Course, fix.Satellites, googleMapsURL(fix.Latitude, fix.Longitude))
+}
+
+// gpsReporter reports the position every GPSReportIntervalSecs once it moved GPSReportMinMoveMeters
+// since the last report, or after GPSReportMaxIntervalSecs without a report
+func (b *Talkkonnect) gpsReporter() {
+	var last GPSFix
+	var lastReport time.Time
+	var lastClient *gumble.Client
+
+	for {
+		interval := GPSReportIntervalSecs
+		if interval <= 0 {
+			interval = 30
+		}
+		time.Sleep(time.Duration(interval) * time.Second)
+
+		if GPSReportMode == gpsReportModeOff || !IsConnected {
+			continue
+		}
+
+		fix := getGPSFix()
+		if !fix.Valid {
+			continue
+		}
+
+		// the comment is lost with the connection, so the first report on a new one always goes out
+		due := lastReport.IsZero() || b.Client != lastClient
+		if !due && distanceMeters(last.Latitude, last.Longitude, fix.Latitude, fix.Longitude) >= GPSReportMinMoveMeters {
+			due = true
+		}
+		if !due && GPSReportMaxIntervalSecs > 0 && time.Since(lastReport) >= time.Duration(GPSReportMaxIntervalSecs)*time.Second {
+			due = true
+		}
+		if !due {
+			continue
+		}
+
+		text := gpsPositionText(fix)
+		switch GPSReportMode {
+		case "message":
+			if _, err := b.sendTextMessage(MessageToChannel, "", text); err != nil {
+				log.Println("error: GPS Cannot Send Position Report ", err)
+				continue
+			}
+		default:
+			b.SetComment(text)
+		}
+
+		log.Println("info: GPS Position Reported ", text)
+		last, lastReport, lastClient = fix, time.Now(), b.Client
+	}
+}
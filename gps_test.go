@@ -0,0 +1,166 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * gps_test.go -> talkkonnect tests of the nmea parser with recorded gps sentences
+ */
+
+package talkkonnect
+
+import (
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestGPSSentence(t *testing.T) {
+	tests := []struct {
+		name      string
+		sentence  string
+		wantErr   bool
+		valid     bool
+		latitude  float64
+		longitude float64
+	}{
+		{
+			name:      "gga good checksum",
+			sentence:  "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47",
+			valid:     true,
+			latitude:  48.1173,
+			longitude: 11.516667,
+		},
+		{
+			name:      "rmc good checksum",
+			sentence:  "$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6A",
+			valid:     true,
+			latitude:  48.1173,
+			longitude: 11.516667,
+		},
+		{
+			name:     "gga bad checksum",
+			sentence: "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*48",
+			wantErr:  true,
+		},
+		{
+			name:     "rmc checksum not hex",
+			sentence: "$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*ZZ",
+			wantErr:  true,
+		},
+		{
+			name:     "not nmea",
+			sentence: "GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47",
+			wantErr:  true,
+		},
+		{
+			name:     "gga no fix",
+			sentence: "$GPGGA,000000.00,,,,,0,00,99.99,,,,,,*66",
+		},
+		{
+			name:     "rmc no fix",
+			sentence: "$GPRMC,000000.00,V,,,,,,,150324,,,N*7C",
+		},
+		{
+			name:      "gga southern hemisphere",
+			sentence:  "$GPGGA,092750.000,3352.8540,S,15112.5600,E,1,09,1.0,40.2,M,22.0,M,,*7D",
+			valid:     true,
+			latitude:  -33.8809,
+			longitude: 151.209333,
+		},
+		{
+			name:      "gga western hemisphere",
+			sentence:  "$GPGGA,201530.00,4042.7680,N,07400.3600,W,2,11,0.8,10.0,M,-34.2,M,,*64",
+			valid:     true,
+			latitude:  40.7128,
+			longitude: -74.006,
+		},
+		{
+			name:      "rmc southern and western hemisphere",
+			sentence:  "$GNRMC,201530.00,A,2254.3000,S,04310.5400,W,000.5,180.0,150324,,,A*42",
+			valid:     true,
+			latitude:  -22.905,
+			longitude: -43.175667,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gpsFix = GPSFix{}
+
+			err := gpsSentence(test.sentence)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("gpsSentence() error = %v, wantErr %v", err, test.wantErr)
+			}
+
+			fix := getGPSFix()
+			if fix.Valid != test.valid {
+				t.Fatalf("fix valid = %v, want %v", fix.Valid, test.valid)
+			}
+			if !test.valid {
+				return
+			}
+			if math.Abs(fix.Latitude-test.latitude) > 1e-6 || math.Abs(fix.Longitude-test.longitude) > 1e-6 {
+				t.Errorf("position = %f,%f, want %f,%f", fix.Latitude, fix.Longitude, test.latitude, test.longitude)
+			}
+		})
+	}
+}
+
+// a recorded log replayed the way gpsReadSource reads a file, the last known position is kept
+// once the fix is lost
+func TestGPSReadSentences(t *testing.T) {
+	recorded := strings.Join([]string{
+		"$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47",
+		"$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230324,003.1,W*61",
+		"$GPGSV,3,1,11,03,03,111,00,04,15,270,00,06,01,010,00,13,06,292,00*74",
+		"$GPGGA,123520,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*48",
+		"$GPGGA,000000.00,,,,,0,00,99.99,,,,,,*66",
+	}, "\r\n")
+
+	gpsFix = GPSFix{}
+	GPSReplayDelayMs = 0
+	if err := gpsReadSentences(strings.NewReader(recorded), true); err != io.EOF {
+		t.Fatalf("gpsReadSentences() error = %v, want EOF", err)
+	}
+
+	fix := getGPSFix()
+	if fix.Valid {
+		t.Errorf("fix valid after the fix was lost")
+	}
+	if fix.Satellites != 0 {
+		t.Errorf("satellites = %d, want 0", fix.Satellites)
+	}
+	if fix.Date != "2024-03-23" || fix.Time != "12:35:19" {
+		t.Errorf("date time = %s %s, want 2024-03-23 12:35:19", fix.Date, fix.Time)
+	}
+	if math.Abs(fix.AltitudeM-545.4) > 1e-9 || math.Abs(fix.SpeedKmh-22.4*knotsToKmh) > 1e-9 {
+		t.Errorf("altitude speed = %f %f, want 545.4 %f", fix.AltitudeM, fix.SpeedKmh, 22.4*knotsToKmh)
+	}
+
+	latitude, longitude, known := lastPosition()
+	if !known || math.Abs(latitude-48.1173) > 1e-6 || math.Abs(longitude-11.516667) > 1e-6 {
+		t.Errorf("last position = %f,%f known %v, want 48.117300,11.516667 known true", latitude, longitude, known)
+	}
+}
@@ -0,0 +1,218 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * health.go -> talkkonnect background health monitor of all default accounts with automatic failover
+ */
+
+package talkkonnect
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jdiderik/gumble/gumble"
+)
+
+// serverHealth is the last known health of one default account
+type serverHealth struct {
+	Index               int       `json:"index"`
+	Name                string    `json:"name"`
+	Server              string    `json:"server"`
+	Reachable           bool      `json:"reachable"`
+	LatencyMs           float64   `json:"latencyms"`
+	Users               int       `json:"users"`
+	MaxUsers            int       `json:"maxusers"`
+	LastCheck           time.Time `json:"lastcheck"`
+	LastError           string    `json:"lasterror,omitempty"`
+	ConsecutiveFailures int       `json:"consecutivefailures"`
+	ConsecutiveOK       int       `json:"consecutiveok"`
+	Current             bool      `json:"current"`
+}
+
+var (
+	healthServers []serverHealth
+	healthMutex   sync.Mutex
+)
+
+func getServerHealth() []serverHealth {
+	healthMutex.Lock()
+	defer healthMutex.Unlock()
+
+	servers := make([]serverHealth, len(healthServers))
+	copy(servers, healthServers)
+	for i := range servers {
+		servers[i].Current = servers[i].Index == AccountIndex
+	}
+	return servers
+}
+
+// healthMonitor runs for the life of the process, the health section is re-read on every round
+// so enabling it or changing the interval with a config reload takes effect without a restart,
+// servers are also pinged without failover when only the metrics endpoint needs the latency
+func (b *Talkkonnect) healthMonitor() {
+	for {
+		if HealthEnabled || (APIEnabled && APIMetrics) {
+			b.healthCheck()
+		}
+		if HealthEnabled {
+			b.healthFailover()
+		}
+		time.Sleep(time.Duration(HealthIntervalSecs) * time.Second)
+	}
+}
+
+// healthCheck pings every default account at the same time and records latency and reachability,
+// counters are carried over by account name so they survive accounts being reordered in a reload
+func (b *Talkkonnect) healthCheck() {
+	list := getAccounts()
+	servers := make([]serverHealth, len(list))
+	for i, account := range list {
+		servers[i] = serverHealth{Index: i, Name: account.Name, Server: account.Server}
+	}
+
+	var wg sync.WaitGroup
+	for i := range servers {
+		wg.Add(1)
+		go func(health *serverHealth) {
+			defer wg.Done()
+			health.LastCheck = time.Now()
+			resp, err := gumble.Ping(health.Server, time.Second*1, time.Duration(HealthTimeoutSecs)*time.Second)
+			if err != nil {
+				health.LastError = err.Error()
+				return
+			}
+			health.Reachable = true
+			health.LatencyMs = float64(resp.Ping) / float64(time.Millisecond)
+			health.Users = resp.ConnectedUsers
+			health.MaxUsers = resp.MaximumUsers
+		}(&servers[i])
+	}
+	wg.Wait()
+
+	healthMutex.Lock()
+	defer healthMutex.Unlock()
+
+	previous := make(map[string]serverHealth)
+	for _, health := range healthServers {
+		previous[health.Name+"|"+health.Server] = health
+	}
+
+	for i := range servers {
+		old := previous[servers[i].Name+"|"+servers[i].Server]
+		if healthy(servers[i]) {
+			servers[i].ConsecutiveOK = old.ConsecutiveOK + 1
+		} else {
+			servers[i].ConsecutiveFailures = old.ConsecutiveFailures + 1
+			log.Printf("warn: Health Check of %s [%d] Failed %d Time(s) Reachable=%v Latency=%.1fms %s\n", servers[i].Name, i, servers[i].ConsecutiveFailures, servers[i].Reachable, servers[i].LatencyMs, servers[i].LastError)
+		}
+	}
+
+	healthServers = servers
+}
+
+func healthy(health serverHealth) bool {
+	if !health.Reachable {
+		return false
+	}
+	return HealthMaxLatencyMs <= 0 || health.LatencyMs <= float64(HealthMaxLatencyMs)
+}
+
+// healthFailover moves away from the current account once it failed failurethreshold checks in a row,
+// and back to the preferred account once that passed returnafter checks in a row
+func (b *Talkkonnect) healthFailover() {
+	healthMutex.Lock()
+	servers := make([]serverHealth, len(healthServers))
+	copy(servers, healthServers)
+	healthMutex.Unlock()
+
+	accountMutex.Lock()
+	current := AccountIndex
+	accountMutex.Unlock()
+	if current >= len(servers) {
+		return
+	}
+
+	// names come from the copy of the health list, the account list may have been reloaded since
+	currentName := servers[current].Name
+
+	if HealthPreferredAccount != "" && currentName != HealthPreferredAccount {
+		for _, health := range servers {
+			if health.Name == HealthPreferredAccount && health.ConsecutiveOK >= HealthReturnAfter {
+				log.Printf("info: Preferred Account %s Healthy For %d Checks, Returning From %s\n", health.Name, health.ConsecutiveOK, currentName)
+				b.healthSwitch(health.Index)
+				return
+			}
+		}
+	}
+
+	if servers[current].ConsecutiveFailures < HealthFailureThreshold {
+		return
+	}
+
+	best := -1
+	for _, health := range servers {
+		if health.Index == current || !healthy(health) {
+			continue
+		}
+		if best == -1 || health.LatencyMs < servers[best].LatencyMs {
+			best = health.Index
+		}
+	}
+
+	if best == -1 {
+		log.Println("warn: Current Account ", currentName, " Unhealthy But No Healthy Alternate Account Found")
+		return
+	}
+
+	log.Printf("alert: Current Account %s Failed %d Health Checks, Failing Over to %s (%.1fms)\n", currentName, servers[current].ConsecutiveFailures, servers[best].Name, servers[best].LatencyMs)
+	b.healthSwitch(best)
+}
+
+func (b *Talkkonnect) healthSwitch(index int) {
+	if err := b.connectAccount(index); err != nil {
+		log.Println("error: Health Monitor Cannot Switch Account ", err)
+		return
+	}
+
+	// start counting afresh on the new account so a single bad round does not bounce straight back
+	healthMutex.Lock()
+	for i := range healthServers {
+		healthServers[i].ConsecutiveFailures = 0
+		healthServers[i].ConsecutiveOK = 0
+	}
+	healthMutex.Unlock()
+
+	announce("Connected to " + b.Name)
+}
+
+func (b *Talkkonnect) apiHealth(w http.ResponseWriter, r *http.Request) {
+	if !apiAllowMethod(w, r, http.MethodGet) {
+		return
+	}
+	apiWriteJSON(w, http.StatusOK, getServerHealth())
+}
@@ -0,0 +1,202 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * homeassistant.go -> talkkonnect entities announced to home assistant with mqtt discovery
+ */
+
+package talkkonnect
+
+import (
+	"encoding/json"
+	"log"
+	"regexp"
+)
+
+// characters home assistant does not accept in the node id of a discovery topic
+var haNodeIDInvalid = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+	SWVersion    string   `json:"sw_version"`
+}
+
+// haEntity is the discovery config of one entity, the state and command topics are the talkkonnect
+// mqtt sub topics and the command topic itself so home assistant sends the usual mqtt commands
+type haEntity struct {
+	Name                string   `json:"name"`
+	UniqueID            string   `json:"unique_id"`
+	Icon                string   `json:"icon,omitempty"`
+	DeviceClass         string   `json:"device_class,omitempty"`
+	AvailabilityTopic   string   `json:"availability_topic"`
+	PayloadAvailable    string   `json:"payload_available"`
+	PayloadNotAvailable string   `json:"payload_not_available"`
+	StateTopic          string   `json:"state_topic"`
+	ValueTemplate       string   `json:"value_template,omitempty"`
+	AttributesTopic     string   `json:"json_attributes_topic,omitempty"`
+	CommandTopic        string   `json:"command_topic,omitempty"`
+	CommandTemplate     string   `json:"command_template,omitempty"`
+	PayloadOn           string   `json:"payload_on,omitempty"`
+	PayloadOff          string   `json:"payload_off,omitempty"`
+	StateOn             string   `json:"state_on,omitempty"`
+	StateOff            string   `json:"state_off,omitempty"`
+	Options             []string `json:"options,omitempty"`
+	Min                 *int     `json:"min,omitempty"`
+	Max                 *int     `json:"max,omitempty"`
+	Step                int      `json:"step,omitempty"`
+	Unit                string   `json:"unit_of_measurement,omitempty"`
+	Device              haDevice `json:"device"`
+}
+
+// haNodeID identifies this talkkonnect in discovery topics and unique ids
+func (b *Talkkonnect) haNodeID() string {
+	id := MQTTId
+	if id == "" {
+		id = b.Username
+	}
+	return "talkkonnect_" + haNodeIDInvalid.ReplaceAllString(id, "_")
+}
+
+// haChannelOptions are the channels of the server in channel up/down order, each by the path
+// the Channel command and the channel topic use, the root channel by its name
+func (b *Talkkonnect) haChannelOptions() []string {
+	var options []string
+	if !IsConnected || b.Client == nil {
+		return options
+	}
+	for _, channel := range b.channelNavList() {
+		if path := channelPath(channel); path != "" {
+			options = append(options, path)
+		} else {
+			options = append(options, channel.Name)
+		}
+	}
+	return options
+}
+
+// homeAssistantDiscovery publishes retained discovery configs for the ptt switch, channel select,
+// mute switch, volume number, connection binary sensor and current talker sensor
+func (b *Talkkonnect) homeAssistantDiscovery() {
+	nodeID := b.haNodeID()
+
+	name := HomeAssistantName
+	if name == "" {
+		name = "talkkonnect " + b.Username
+	}
+
+	device := haDevice{
+		Identifiers:  []string{nodeID},
+		Name:         name,
+		Manufacturer: "talkkonnect",
+		Model:        "talkkonnect " + TargetBoard,
+		SWVersion:    talkkonnectVersion,
+	}
+
+	entity := func(object string, entityName string) haEntity {
+		return haEntity{
+			Name:                entityName,
+			UniqueID:            nodeID + "_" + object,
+			AvailabilityTopic:   MQTTTopic + mqttStatusTopic,
+			PayloadAvailable:    mqttOnline,
+			PayloadNotAvailable: mqttOffline,
+			Device:              device,
+		}
+	}
+
+	ptt := entity("ptt", "PTT")
+	ptt.Icon = "mdi:microphone"
+	ptt.StateTopic = MQTTTopic + mqttTxTopic
+	ptt.ValueTemplate = "{{ 'ON' if value_json.transmitting else 'OFF' }}"
+	ptt.CommandTopic = MQTTTopic
+	ptt.PayloadOn, ptt.PayloadOff = "StartTransmitting", "StopTransmitting"
+	ptt.StateOn, ptt.StateOff = "ON", "OFF"
+	b.haPublish("switch", nodeID, "ptt", ptt)
+
+	// home assistant rejects a select without options, it is announced once connected to a server
+	if options := b.haChannelOptions(); len(options) > 0 {
+		channel := entity("channel", "Channel")
+		channel.Icon = "mdi:radio-tower"
+		channel.StateTopic = MQTTTopic + mqttChannelTopic
+		channel.ValueTemplate = "{{ value_json.path if value_json.path else value_json.channel }}"
+		channel.AttributesTopic = MQTTTopic + mqttChannelTopic
+		channel.CommandTopic = MQTTTopic
+		channel.CommandTemplate = "Channel:{{ value }}"
+		channel.Options = options
+		b.haPublish("select", nodeID, "channel", channel)
+	}
+
+	mute := entity("mute", "Mute")
+	mute.Icon = "mdi:volume-off"
+	mute.StateTopic = MQTTTopic + mqttVolumeTopic
+	mute.ValueTemplate = "{{ 'ON' if value_json.muted else 'OFF' }}"
+	mute.CommandTopic = MQTTTopic
+	mute.PayloadOn, mute.PayloadOff = "Mute", "Unmute"
+	mute.StateOn, mute.StateOff = "ON", "OFF"
+	b.haPublish("switch", nodeID, "mute", mute)
+
+	minVolume, maxVolume := 0, 100
+	volume := entity("volume", "Volume")
+	volume.Icon = "mdi:volume-high"
+	volume.StateTopic = MQTTTopic + mqttVolumeTopic
+	volume.ValueTemplate = "{{ value_json.volume }}"
+	volume.CommandTopic = MQTTTopic
+	volume.CommandTemplate = "Volume:{{ value | int }}"
+	volume.Min, volume.Max, volume.Step = &minVolume, &maxVolume, 1
+	volume.Unit = "%"
+	b.haPublish("number", nodeID, "volume", volume)
+
+	connected := entity("connected", "Connected")
+	connected.DeviceClass = "connectivity"
+	connected.StateTopic = MQTTTopic + mqttChannelTopic
+	connected.ValueTemplate = "{{ 'ON' if value_json.connected else 'OFF' }}"
+	connected.AttributesTopic = MQTTTopic + mqttChannelTopic
+	b.haPublish("binary_sensor", nodeID, "connected", connected)
+
+	talker := entity("talker", "Current Talker")
+	talker.Icon = "mdi:account-voice"
+	talker.StateTopic = MQTTTopic + mqttTalkerTopic
+	talker.ValueTemplate = "{{ value_json.user if value_json.talking else '' }}"
+	talker.AttributesTopic = MQTTTopic + mqttTalkerTopic
+	b.haPublish("sensor", nodeID, "talker", talker)
+
+	log.Println("info: Home Assistant Discovery Published Under ", HomeAssistantPrefix+"/+/"+nodeID)
+}
+
+// haPublish publishes one retained config to <discoveryprefix>/<component>/<nodeid>/<object>/config
+func (b *Talkkonnect) haPublish(component string, nodeID string, object string, config haEntity) {
+	topic := HomeAssistantPrefix + "/" + component + "/" + nodeID + "/" + object + "/config"
+
+	payload, err := json.Marshal(config)
+	if err != nil {
+		log.Println("error: Cannot Encode Home Assistant Discovery ", err)
+		return
+	}
+	if err := mqttPublish(topic, true, payload); err != nil {
+		log.Println("error: Cannot Publish Home Assistant Discovery to ", topic, err)
+	}
+}
@@ -30,15 +30,23 @@
 package talkkonnect
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jdiderik/gumble/gumble"
 )
 
 func (b *Talkkonnect) httpAPI(w http.ResponseWriter, r *http.Request) {
 	commands, ok := r.URL.Query()["command"]
 	if !ok || len(commands[0]) < 1 {
 		log.Println("error: URL Param 'command' is missing example http api commands should be of the format http://a.b.c.d/?command=StartTransmitting")
+		w.WriteHeader(http.StatusBadRequest)
 		fmt.Fprintf(w, "error: API should be of the format http://a.b.c.d:"+APIListenPort+"/?command=StartTransmitting\n")
 		return
 	}
@@ -52,6 +60,7 @@ func (b *Talkkonnect) httpAPI(w http.ResponseWriter, r *http.Request) {
 			b.cmdDisplayMenu()
 			fmt.Fprintf(w, "API Display Menu Request Processed Successfully\n")
 		} else {
+			w.WriteHeader(http.StatusForbidden)
 			fmt.Fprintf(w, "API Display Menu Request Denied\n")
 		}
 	case "ChannelUp":
@@ -59,6 +68,7 @@ func (b *Talkkonnect) httpAPI(w http.ResponseWriter, r *http.Request) {
 			b.cmdChannelUp()
 			fmt.Fprintf(w, "API Channel Up Request Processed Successfully\n")
 		} else {
+			w.WriteHeader(http.StatusForbidden)
 			fmt.Fprintf(w, "API Channel Up Request Denied\n")
 		}
 	case "ChannelDown":
@@ -66,6 +76,7 @@ func (b *Talkkonnect) httpAPI(w http.ResponseWriter, r *http.Request) {
 			b.cmdChannelDown()
 			fmt.Fprintf(w, "API Channel Down Request Processed Successfully\n")
 		} else {
+			w.WriteHeader(http.StatusForbidden)
 			fmt.Fprintf(w, "API Channel Down Request Denied\n")
 		}
 	case "ListChannels":
@@ -73,6 +84,7 @@ func (b *Talkkonnect) httpAPI(w http.ResponseWriter, r *http.Request) {
 			b.cmdListServerChannels()
 			fmt.Fprintf(w, "API List Server Channels Request Processed Successfully\n")
 		} else {
+			w.WriteHeader(http.StatusForbidden)
 			fmt.Fprintf(w, "API List Server Channels Request Denied\n")
 		}
 	case "StartTransmitting":
@@ -80,6 +92,7 @@ func (b *Talkkonnect) httpAPI(w http.ResponseWriter, r *http.Request) {
 			b.cmdStartTransmitting()
 			fmt.Fprintf(w, "API Start Transmitting Request Processed Successfully\n")
 		} else {
+			w.WriteHeader(http.StatusForbidden)
 			fmt.Fprintf(w, "API Start Transmitting Request Denied\n")
 		}
 	case "StopTransmitting":
@@ -87,6 +100,7 @@ func (b *Talkkonnect) httpAPI(w http.ResponseWriter, r *http.Request) {
 			b.cmdStopTransmitting()
 			fmt.Fprintf(w, "API Stop Transmitting Request Processed Successfully\n")
 		} else {
+			w.WriteHeader(http.StatusForbidden)
 			fmt.Fprintf(w, "API Stop Transmitting Request Denied\n")
 		}
 	case "ListOnlineUsers":
@@ -94,6 +108,7 @@ func (b *Talkkonnect) httpAPI(w http.ResponseWriter, r *http.Request) {
 			b.cmdListOnlineUsers()
 			fmt.Fprintf(w, "API List Online Users Request Processed Successfully\n")
 		} else {
+			w.WriteHeader(http.StatusForbidden)
 			fmt.Fprintf(w, "API List Online Users Request Denied\n")
 		}
 	case "Stream-Toggle":
@@ -101,6 +116,7 @@ func (b *Talkkonnect) httpAPI(w http.ResponseWriter, r *http.Request) {
 			b.cmdPlayback()
 			fmt.Fprintf(w, "API Play/Stop Stream Request Processed Successfully\n")
 		} else {
+			w.WriteHeader(http.StatusForbidden)
 			fmt.Fprintf(w, "API Play/Stop Stream Request Denied\n")
 		}
 
@@ -109,6 +125,7 @@ func (b *Talkkonnect) httpAPI(w http.ResponseWriter, r *http.Request) {
 			b.cmdClearScreen()
 			fmt.Fprintf(w, "API Clear Screen Processed Successfully\n")
 		} else {
+			w.WriteHeader(http.StatusForbidden)
 			fmt.Fprintf(w, "API Clear Screen Denied\n")
 		}
 	case "PingServers":
@@ -116,6 +133,7 @@ func (b *Talkkonnect) httpAPI(w http.ResponseWriter, r *http.Request) {
 			b.cmdPingServers()
 			fmt.Fprintf(w, "API Ping Servers Processed Successfully\n")
 		} else {
+			w.WriteHeader(http.StatusForbidden)
 			fmt.Fprintf(w, "API Ping Servers Denied\n")
 		}
 	case "ShowUptime":
@@ -123,9 +141,792 @@ func (b *Talkkonnect) httpAPI(w http.ResponseWriter, r *http.Request) {
 			b.cmdShowUptime()
 			fmt.Fprintf(w, "API Request Current Version Successfully\n")
 		} else {
+			w.WriteHeader(http.StatusForbidden)
 			fmt.Fprintf(w, "API Request Current Version Denied\n")
 		}
+	case "ConnNextServer":
+		if APINextServer {
+			b.cmdConnNextServer()
+			fmt.Fprintf(w, "API Connect to Next Server Request Processed Successfully\n")
+		} else {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "API Connect to Next Server Request Denied\n")
+		}
+	case "ConnPreviousServer":
+		if APIPreviousServer {
+			b.cmdConnPreviousServer()
+			fmt.Fprintf(w, "API Connect to Previous Server Request Processed Successfully\n")
+		} else {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "API Connect to Previous Server Request Denied\n")
+		}
+	case "ReloadConfig":
+		if APIReloadConfig {
+			b.cmdReloadConfig()
+			fmt.Fprintf(w, "API Reload XML Config Request Processed Successfully\n")
+		} else {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "API Reload XML Config Request Denied\n")
+		}
+	case "ScanChannels":
+		if APIScanChannels {
+			b.cmdScanChannels()
+			fmt.Fprintf(w, "API Scan Channels Start/Stop Request Processed Successfully\n")
+		} else {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "API Scan Channels Request Denied\n")
+		}
+	case "Preset":
+		if APIPreset {
+			numbers, ok := r.URL.Query()["number"]
+			if !ok || len(numbers[0]) < 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "API Recall Preset Request Needs a Number e.g. ?command=Preset&number=12\n")
+				return
+			}
+			number, err := strconv.Atoi(numbers[0])
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "API Recall Preset Number %q is Invalid\n", numbers[0])
+				return
+			}
+			if err := b.recallPreset(number); err != nil {
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprintf(w, "API Recall Preset Request Failed %v\n", err)
+				return
+			}
+			fmt.Fprintf(w, "API Recall Preset %d Request Processed Successfully\n", number)
+		} else {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "API Recall Preset Request Denied\n")
+		}
+	case "Call":
+		if APICall {
+			query := r.URL.Query()
+			var request string
+			switch {
+			case query.Get("name") != "":
+				request = query.Get("name")
+			case query.Get("user") != "":
+				request = "user:" + query.Get("user")
+			case query.Get("channel") != "" && query.Get("subchannels") == "true":
+				request = "subchannels:" + query.Get("channel")
+			case query.Get("channel") != "":
+				request = "channel:" + query.Get("channel")
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "API Call Request Needs a Target e.g. ?command=Call&name=Supervisor, &user=bob,alice or &channel=Ops&subchannels=true\n")
+				return
+			}
+			call, err := parseCall(request)
+			if err != nil {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprintf(w, "API Call Request Failed %v\n", err)
+				return
+			}
+			if err := b.startCall(call); err != nil {
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprintf(w, "API Call Request Failed %v\n", err)
+				return
+			}
+			fmt.Fprintf(w, "API %s Call to %s Request Processed Successfully\n", strings.Title(call.kind()), call.Name)
+		} else {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "API Call Request Denied\n")
+		}
+	case "EndCall":
+		if APICall {
+			b.cmdEndCall()
+			fmt.Fprintf(w, "API End Call Request Processed Successfully\n")
+		} else {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "API End Call Request Denied\n")
+		}
+	case "GPSPosition":
+		if APIRequestGpsPosition {
+			fix := getGPSFix()
+			if !GPSEnabled || !fix.Valid {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprintf(w, "API GPS Position Not Available, No GPS Fix\n")
+				return
+			}
+			fmt.Fprintf(w, "API GPS %s\n", gpsPositionText(fix))
+		} else {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "API GPS Position Request Denied\n")
+		}
+	case "PanicSimulation":
+		if APIPanicSimulation {
+			var err error
+			switch r.URL.Query().Get("state") {
+			case "on":
+				err = b.startPanic()
+			case "off":
+				b.cancelPanic()
+			default:
+				err = b.togglePanic()
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprintf(w, "API Panic Simulation Request Failed %v\n", err)
+				return
+			}
+			fmt.Fprintf(w, "API Panic Simulation Request Processed Successfully, Panic Active %v\n", panicActive())
+		} else {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "API Panic Simulation Request Denied\n")
+		}
+	case "SendMessage":
+		if APITextMessage {
+			query := r.URL.Query()
+			to, name := MessageToChannel, query.Get("channel")
+			if query.Get("tree") != "" {
+				to, name = MessageToTree, query.Get("tree")
+			} else if query.Get("user") != "" {
+				to, name = MessageToUser, query.Get("user")
+			}
+			if _, err := b.sendTextMessage(to, name, query.Get("text")); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "API Send Message Request Failed %v\n", err)
+				return
+			}
+			fmt.Fprintf(w, "API Send Message Request Processed Successfully\n")
+		} else {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "API Send Message Request Denied\n")
+		}
 	default:
+		w.WriteHeader(http.StatusNotFound)
 		fmt.Fprintf(w, "API Command Not Defined\n")
 	}
 }
+
+// JSON REST API (v1), served on the same listener as the legacy ?command= api
+
+type apiResponse struct {
+	Status string      `json:"status"`
+	Error  string      `json:"error,omitempty"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+type apiStatusStruct struct {
+	Version       string          `json:"version"`
+	Connected     bool            `json:"connected"`
+	AccountIndex  int             `json:"accountindex"`
+	AccountName   string          `json:"accountname"`
+	Server        string          `json:"server"`
+	Username      string          `json:"username"`
+	Ident         string          `json:"ident"`
+	ChannelID     uint32          `json:"channelid"`
+	ChannelName   string          `json:"channelname"`
+	ChannelUsers  int             `json:"channelusers"`
+	Transmitting  bool            `json:"transmitting"`
+	PlayingStream bool            `json:"playingstream"`
+	UptimeSeconds int64           `json:"uptimeseconds"`
+	Reconnect     reconnectStatus `json:"reconnect"`
+}
+
+type apiChannelStruct struct {
+	ID       uint32 `json:"id"`
+	Name     string `json:"name"`
+	ParentID uint32 `json:"parentid"`
+	IsRoot   bool   `json:"isroot"`
+	Users    int    `json:"users"`
+	Current  bool   `json:"current"`
+}
+
+type apiUserStruct struct {
+	Session      uint32 `json:"session"`
+	Name         string `json:"name"`
+	ChannelID    uint32 `json:"channelid"`
+	ChannelName  string `json:"channelname"`
+	Comment      string `json:"comment"`
+	SelfMuted    bool   `json:"selfmuted"`
+	SelfDeafened bool   `json:"selfdeafened"`
+	Self         bool   `json:"self"`
+}
+
+type apiChannelRequest struct {
+	ID        *uint32 `json:"id"`
+	Name      string  `json:"name"`
+	Direction string  `json:"direction"`
+}
+
+type apiServerRequest struct {
+	Direction string `json:"direction"`
+}
+
+type apiPresetRequest struct {
+	Number int `json:"number"`
+}
+
+type apiCallRequest struct {
+	Name        string   `json:"name"`
+	Users       []string `json:"users"`
+	Channel     string   `json:"channel"`
+	SubChannels bool     `json:"subchannels"`
+	End         bool     `json:"end"`
+}
+
+type apiMessageRequest struct {
+	Text    string `json:"text"`
+	Channel string `json:"channel"`
+	Tree    string `json:"tree"`
+	User    string `json:"user"`
+}
+
+type apiGPSStruct struct {
+	Enabled bool `json:"enabled"`
+	GPSFix
+}
+
+type apiPanicStruct struct {
+	Enabled bool `json:"enabled"`
+	Active  bool `json:"active"`
+}
+
+type apiPanicRequest struct {
+	Active *bool `json:"active"`
+}
+
+type apiTxRequest struct {
+	Transmit *bool `json:"transmit"`
+}
+
+func (b *Talkkonnect) startHTTPAPI() {
+	http.HandleFunc("/", b.httpAPI)
+	http.HandleFunc("/api/v1/status", b.apiStatus)
+	http.HandleFunc("/api/v1/channels", b.apiChannels)
+	http.HandleFunc("/api/v1/users", b.apiUsers)
+	http.HandleFunc("/api/v1/channel", b.apiChannel)
+	http.HandleFunc("/api/v1/tx", b.apiTx)
+	http.HandleFunc("/api/v1/server", b.apiServer)
+	http.HandleFunc("/api/v1/health", b.apiHealth)
+	http.HandleFunc("/api/v1/presets", b.apiPresets)
+	http.HandleFunc("/api/v1/call", b.apiCall)
+	http.HandleFunc("/api/v1/messages", b.apiMessages)
+	http.HandleFunc("/api/v1/panic", b.apiPanic)
+	http.HandleFunc("/api/v1/gps", b.apiGPS)
+	http.HandleFunc("/metrics", b.apiMetrics)
+	http.HandleFunc("/api/v1/events", b.apiEvents)
+	http.HandleFunc("/api/v1/reload", b.apiReload)
+
+	HTTPServRunning = true
+	if err := http.ListenAndServe(":"+APIListenPort, nil); err != nil {
+		FatalCleanUp("Problem Starting HTTP API Server " + err.Error())
+	}
+}
+
+func apiWriteJSON(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(apiResponse{Status: "ok", Data: data}); err != nil {
+		log.Println("error: Cannot Encode API Response ", err)
+	}
+}
+
+func apiWriteError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(apiResponse{Status: "error", Error: message}); err != nil {
+		log.Println("error: Cannot Encode API Response ", err)
+	}
+}
+
+func apiAllowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		apiWriteError(w, http.StatusMethodNotAllowed, "method "+r.Method+" not allowed, use "+method)
+		return false
+	}
+	return true
+}
+
+func (b *Talkkonnect) apiStatusData() apiStatusStruct {
+	status := apiStatusStruct{
+		Version:       talkkonnectVersion,
+		Connected:     IsConnected,
+		AccountIndex:  AccountIndex,
+		AccountName:   b.Name,
+		Server:        b.Address,
+		Username:      b.Config.Username,
+		Ident:         b.Ident,
+		Transmitting:  b.IsTransmitting,
+		PlayingStream: IsPlayStream,
+		UptimeSeconds: int64(time.Since(StartTime).Seconds()),
+		Reconnect:     getReconnectStatus(),
+	}
+
+	if IsConnected && b.Client != nil && b.Client.Self != nil && b.Client.Self.Channel != nil {
+		status.ChannelID = b.Client.Self.Channel.ID
+		status.ChannelName = b.Client.Self.Channel.Name
+		status.ChannelUsers = len(b.Client.Self.Channel.Users)
+	}
+
+	return status
+}
+
+func (b *Talkkonnect) apiStatus(w http.ResponseWriter, r *http.Request) {
+	if !apiAllowMethod(w, r, http.MethodGet) {
+		return
+	}
+	apiWriteJSON(w, http.StatusOK, b.apiStatusData())
+}
+
+func (b *Talkkonnect) apiChannels(w http.ResponseWriter, r *http.Request) {
+	if !apiAllowMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !APIListServerChannels {
+		apiWriteError(w, http.StatusForbidden, "list server channels denied by config")
+		return
+	}
+	if !IsConnected {
+		apiWriteError(w, http.StatusServiceUnavailable, "not connected to mumble server")
+		return
+	}
+
+	channels := make([]apiChannelStruct, 0, len(b.Client.Channels))
+	for _, ch := range b.Client.Channels {
+		channel := apiChannelStruct{
+			ID:      ch.ID,
+			Name:    ch.Name,
+			IsRoot:  ch.Parent == nil,
+			Users:   len(ch.Users),
+			Current: ch.ID == b.Client.Self.Channel.ID,
+		}
+		if ch.Parent != nil {
+			channel.ParentID = ch.Parent.ID
+		}
+		channels = append(channels, channel)
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].ID < channels[j].ID })
+
+	apiWriteJSON(w, http.StatusOK, channels)
+}
+
+func (b *Talkkonnect) apiUsers(w http.ResponseWriter, r *http.Request) {
+	if !apiAllowMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !APIListOnlineUsers {
+		apiWriteError(w, http.StatusForbidden, "list online users denied by config")
+		return
+	}
+	if !IsConnected {
+		apiWriteError(w, http.StatusServiceUnavailable, "not connected to mumble server")
+		return
+	}
+
+	// ?channel=current limits the list to the users in our own channel
+	currentOnly := r.URL.Query().Get("channel") == "current"
+
+	users := make([]apiUserStruct, 0, len(b.Client.Users))
+	for _, usr := range b.Client.Users {
+		if usr.Channel == nil || (currentOnly && usr.Channel.ID != b.Client.Self.Channel.ID) {
+			continue
+		}
+		users = append(users, apiUserStruct{
+			Session:      usr.Session,
+			Name:         usr.Name,
+			ChannelID:    usr.Channel.ID,
+			ChannelName:  usr.Channel.Name,
+			Comment:      usr.Comment,
+			SelfMuted:    usr.SelfMuted,
+			SelfDeafened: usr.SelfDeafened,
+			Self:         usr.Session == b.Client.Self.Session,
+		})
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Session < users[j].Session })
+
+	apiWriteJSON(w, http.StatusOK, users)
+}
+
+func (b *Talkkonnect) apiChannel(w http.ResponseWriter, r *http.Request) {
+	if !apiAllowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var request apiChannelRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apiWriteError(w, http.StatusBadRequest, "invalid json body "+err.Error())
+		return
+	}
+
+	if !IsConnected {
+		apiWriteError(w, http.StatusServiceUnavailable, "not connected to mumble server")
+		return
+	}
+
+	switch {
+	case request.Direction == "up":
+		if !APIChannelUp {
+			apiWriteError(w, http.StatusForbidden, "channel up denied by config")
+			return
+		}
+		b.cmdChannelUp()
+	case request.Direction == "down":
+		if !APIChannelDown {
+			apiWriteError(w, http.StatusForbidden, "channel down denied by config")
+			return
+		}
+		b.cmdChannelDown()
+	case request.Direction != "":
+		apiWriteError(w, http.StatusBadRequest, "direction must be up or down")
+		return
+	case request.ID != nil || request.Name != "":
+		if !APIChangeChannel {
+			apiWriteError(w, http.StatusForbidden, "change channel denied by config")
+			return
+		}
+
+		var channel *gumble.Channel
+		if request.ID != nil {
+			channel = b.Client.Channels[*request.ID]
+		} else {
+			channel = b.Client.Channels.Find(request.Name)
+		}
+		if channel == nil {
+			apiWriteError(w, http.StatusNotFound, "channel not found")
+			return
+		}
+
+		log.Println("info: API Change Channel Requested to ", channel.Name, " ID ", channel.ID)
+		b.Client.Self.Move(channel)
+		prevChannelID = channel.ID
+	default:
+		apiWriteError(w, http.StatusBadRequest, "one of id, name or direction is required")
+		return
+	}
+
+	// the move is confirmed asynchronously by the server, so report what we asked for
+	apiWriteJSON(w, http.StatusAccepted, b.apiStatusData())
+}
+
+func (b *Talkkonnect) apiServer(w http.ResponseWriter, r *http.Request) {
+	if !apiAllowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var request apiServerRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apiWriteError(w, http.StatusBadRequest, "invalid json body "+err.Error())
+		return
+	}
+
+	var step int
+	switch request.Direction {
+	case "next":
+		if !APINextServer {
+			apiWriteError(w, http.StatusForbidden, "next server denied by config")
+			return
+		}
+		step = 1
+	case "previous":
+		if !APIPreviousServer {
+			apiWriteError(w, http.StatusForbidden, "previous server denied by config")
+			return
+		}
+		step = -1
+	default:
+		apiWriteError(w, http.StatusBadRequest, "direction must be next or previous")
+		return
+	}
+
+	log.Println("info: API Server Change Requested ", request.Direction)
+	if err := b.hopServer(step); err != nil {
+		apiWriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	apiWriteJSON(w, http.StatusOK, b.apiStatusData())
+}
+
+// apiPresets lists the presets on GET and recalls one on POST {"number":12}
+func (b *Talkkonnect) apiPresets(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		apiWriteJSON(w, http.StatusOK, getPresets())
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		apiWriteError(w, http.StatusMethodNotAllowed, "method "+r.Method+" not allowed, use GET or POST")
+		return
+	}
+
+	if !APIPreset {
+		apiWriteError(w, http.StatusForbidden, "preset denied by config")
+		return
+	}
+
+	var request apiPresetRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apiWriteError(w, http.StatusBadRequest, "invalid json body "+err.Error())
+		return
+	}
+
+	if _, ok := Presets[request.Number]; !ok {
+		apiWriteError(w, http.StatusNotFound, fmt.Sprintf("preset %d not defined", request.Number))
+		return
+	}
+
+	log.Println("info: API Recall Preset Requested ", request.Number)
+	if err := b.recallPreset(request.Number); err != nil {
+		apiWriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	apiWriteJSON(w, http.StatusAccepted, b.apiStatusData())
+}
+
+// apiCall reports the call in progress and the configured calls on GET, on POST it starts a call with
+// {"name":"Supervisor"}, {"users":["bob"]} or {"channel":"Ops","subchannels":true} and ends it with {"end":true}
+func (b *Talkkonnect) apiCall(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		apiWriteJSON(w, http.StatusOK, getCallStatus())
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		apiWriteError(w, http.StatusMethodNotAllowed, "method "+r.Method+" not allowed, use GET or POST")
+		return
+	}
+
+	if !APICall {
+		apiWriteError(w, http.StatusForbidden, "call denied by config")
+		return
+	}
+
+	var request apiCallRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apiWriteError(w, http.StatusBadRequest, "invalid json body "+err.Error())
+		return
+	}
+
+	if request.End {
+		log.Println("info: API End Call Requested")
+		b.endCall("ended")
+		apiWriteJSON(w, http.StatusOK, getCallStatus())
+		return
+	}
+
+	if !IsConnected {
+		apiWriteError(w, http.StatusServiceUnavailable, "not connected to a server")
+		return
+	}
+
+	var call VoiceCall
+	switch {
+	case request.Name != "":
+		found, err := parseCall(request.Name)
+		if err != nil {
+			apiWriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		call = found
+	case len(request.Users) > 0:
+		call = VoiceCall{Name: strings.Join(request.Users, ", "), Users: request.Users}
+	case request.Channel != "":
+		call = VoiceCall{Name: request.Channel, Channels: []VoiceCallChannel{{Name: request.Channel, SubChannels: request.SubChannels}}}
+	default:
+		apiWriteError(w, http.StatusBadRequest, "name, users, channel or end is required")
+		return
+	}
+
+	log.Println("info: API Call Requested ", call.Name)
+	if err := b.startCall(call); err != nil {
+		apiWriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	apiWriteJSON(w, http.StatusOK, getCallStatus())
+}
+
+// apiMessages returns the message history on GET (?since=id&limit=n), on POST it sends {"text":"..."} to
+// the current channel, or to {"channel":"name"}, {"tree":"name"} or {"user":"name"}
+func (b *Talkkonnect) apiMessages(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodPost:
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		apiWriteError(w, http.StatusMethodNotAllowed, "method "+r.Method+" not allowed, use GET or POST")
+		return
+	}
+
+	if !APITextMessage {
+		apiWriteError(w, http.StatusForbidden, "text message denied by config")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		var since uint64
+		var limit int
+		if value := r.URL.Query().Get("since"); value != "" {
+			parsed, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				apiWriteError(w, http.StatusBadRequest, "since must be a message id")
+				return
+			}
+			since = parsed
+		}
+		if value := r.URL.Query().Get("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				apiWriteError(w, http.StatusBadRequest, "limit must be a positive number")
+				return
+			}
+			limit = parsed
+		}
+		apiWriteJSON(w, http.StatusOK, getMessages(since, limit))
+		return
+	}
+
+	if !IsConnected {
+		apiWriteError(w, http.StatusServiceUnavailable, "not connected to a server")
+		return
+	}
+
+	var request apiMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apiWriteError(w, http.StatusBadRequest, "invalid json body "+err.Error())
+		return
+	}
+
+	to, name := MessageToChannel, request.Channel
+	switch {
+	case request.Tree != "":
+		to, name = MessageToTree, request.Tree
+	case request.User != "":
+		to, name = MessageToUser, request.User
+	}
+
+	log.Printf("info: API Send Message to %s %q Requested\n", to, name)
+	message, err := b.sendTextMessage(to, name, request.Text)
+	if err != nil {
+		apiWriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	apiWriteJSON(w, http.StatusOK, message)
+}
+
+// apiGPS reports the gps fix, valid is false without a fix with the last known position still filled in
+func (b *Talkkonnect) apiGPS(w http.ResponseWriter, r *http.Request) {
+	if !apiAllowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	if !APIRequestGpsPosition {
+		apiWriteError(w, http.StatusForbidden, "request gps position denied by config")
+		return
+	}
+
+	apiWriteJSON(w, http.StatusOK, apiGPSStruct{Enabled: GPSEnabled, GPSFix: getGPSFix()})
+}
+
+// apiPanic reports panic mode on GET, on POST {"active":true} raises the alarm and {"active":false} cancels it
+func (b *Talkkonnect) apiPanic(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		apiWriteJSON(w, http.StatusOK, apiPanicStruct{Enabled: PEnabled, Active: panicActive()})
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		apiWriteError(w, http.StatusMethodNotAllowed, "method "+r.Method+" not allowed, use GET or POST")
+		return
+	}
+
+	if !APIPanicSimulation {
+		apiWriteError(w, http.StatusForbidden, "panic simulation denied by config")
+		return
+	}
+
+	var request apiPanicRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apiWriteError(w, http.StatusBadRequest, "invalid json body "+err.Error())
+		return
+	}
+	if request.Active == nil {
+		apiWriteError(w, http.StatusBadRequest, "active is required")
+		return
+	}
+
+	if *request.Active {
+		log.Println("info: API Panic Requested")
+		if err := b.startPanic(); err != nil {
+			apiWriteError(w, http.StatusConflict, err.Error())
+			return
+		}
+	} else {
+		log.Println("info: API Panic Cancel Requested")
+		b.cancelPanic()
+	}
+
+	apiWriteJSON(w, http.StatusOK, apiPanicStruct{Enabled: PEnabled, Active: panicActive()})
+}
+
+func (b *Talkkonnect) apiTx(w http.ResponseWriter, r *http.Request) {
+	if !apiAllowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var request apiTxRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Transmit == nil {
+		apiWriteError(w, http.StatusBadRequest, "json body must be of the form {\"transmit\": true|false}")
+		return
+	}
+
+	if !IsConnected {
+		apiWriteError(w, http.StatusServiceUnavailable, "not connected to mumble server")
+		return
+	}
+
+	if *request.Transmit {
+		if !APIStartTransmitting {
+			apiWriteError(w, http.StatusForbidden, "start transmitting denied by config")
+			return
+		}
+		if b.IsTransmitting {
+			apiWriteError(w, http.StatusConflict, "already transmitting")
+			return
+		}
+		if remaining := txLockedOut(); remaining > 0 {
+			apiWriteError(w, http.StatusConflict, fmt.Sprintf("tx locked out after time out, try again in %v", remaining.Round(time.Second)))
+			return
+		}
+		b.cmdStartTransmitting()
+	} else {
+		if !APIStopTransmitting {
+			apiWriteError(w, http.StatusForbidden, "stop transmitting denied by config")
+			return
+		}
+		if !b.IsTransmitting {
+			apiWriteError(w, http.StatusConflict, "not transmitting")
+			return
+		}
+		b.cmdStopTransmitting()
+	}
+
+	apiWriteJSON(w, http.StatusOK, b.apiStatusData())
+}
+
+func (b *Talkkonnect) apiReload(w http.ResponseWriter, r *http.Request) {
+	if !apiAllowMethod(w, r, http.MethodPost) {
+		return
+	}
+	if !APIReloadConfig {
+		apiWriteError(w, http.StatusForbidden, "reload config denied by config")
+		return
+	}
+
+	changes, err := b.reloadConfig()
+	if err != nil {
+		apiWriteError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	apiWriteJSON(w, http.StatusOK, changes)
+}
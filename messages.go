@@ -0,0 +1,187 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * messages.go -> talkkonnect text messages to channels, channel trees and users and the message history
+ */
+
+package talkkonnect
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jdiderik/gumble/gumble"
+)
+
+// text message targets
+const (
+	MessageToChannel = "channel"
+	MessageToTree    = "tree"
+	MessageToUser    = "user"
+)
+
+// TextMessage is a message sent or received, kept in the message history
+type TextMessage struct {
+	ID        uint64    `json:"id"`
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	Sender    string    `json:"sender,omitempty"`
+	Channels  []string  `json:"channels,omitempty"`
+	Trees     []string  `json:"trees,omitempty"`
+	Users     []string  `json:"users,omitempty"`
+	Private   bool      `json:"private"`
+	Message   string    `json:"message"`
+}
+
+var (
+	messageHistoryMutex sync.Mutex
+	messageHistory      []TextMessage
+	messageHistoryID    uint64
+)
+
+// recordMessage adds a message to the history, dropping the oldest once it holds MessageHistorySize
+func recordMessage(message TextMessage) TextMessage {
+	messageHistoryMutex.Lock()
+	defer messageHistoryMutex.Unlock()
+
+	messageHistoryID++
+	message.ID = messageHistoryID
+	if message.Time.IsZero() {
+		message.Time = time.Now()
+	}
+
+	if MessageHistorySize <= 0 {
+		return message
+	}
+
+	messageHistory = append(messageHistory, message)
+	if excess := len(messageHistory) - MessageHistorySize; excess > 0 {
+		messageHistory = append([]TextMessage(nil), messageHistory[excess:]...)
+	}
+	return message
+}
+
+// getMessages returns the messages after message id since, oldest first, at most limit (0 for all)
+func getMessages(since uint64, limit int) []TextMessage {
+	messageHistoryMutex.Lock()
+	defer messageHistoryMutex.Unlock()
+
+	messages := []TextMessage{}
+	for _, message := range messageHistory {
+		if message.ID > since {
+			messages = append(messages, message)
+		}
+	}
+	if limit > 0 && len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	return messages
+}
+
+// receivedMessage turns a text message event into a history entry, mumble messages are html so
+// the markup is stripped to keep the text
+func receivedMessage(e *gumble.TextMessageEvent) TextMessage {
+	message := TextMessage{Direction: "rx", Private: len(e.Users) > 0, Message: strings.TrimSpace(esc(e.Message))}
+	if e.Sender != nil {
+		message.Sender = e.Sender.Name
+	}
+	for _, channel := range e.Channels {
+		message.Channels = append(message.Channels, channelPath(channel))
+	}
+	for _, channel := range e.Trees {
+		message.Trees = append(message.Trees, channelPath(channel))
+	}
+	for _, user := range e.Users {
+		message.Users = append(message.Users, user.Name)
+	}
+	return message
+}
+
+// sendTextMessage sends text to the current channel (to channel with an empty name), a named
+// channel, a channel and all of its subchannels (tree) or a user
+func (b *Talkkonnect) sendTextMessage(to string, name string, text string) (TextMessage, error) {
+	if !IsConnected {
+		return TextMessage{}, errors.New("Not Connected to a Server")
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return TextMessage{}, errors.New("Message Text is Empty")
+	}
+
+	// the text is sent as plain text, mumble would otherwise interpret it as html
+	body := html.EscapeString(text)
+	message := TextMessage{Direction: "tx", Sender: b.Client.Self.Name, Message: text}
+
+	switch to {
+	case MessageToChannel, MessageToTree:
+		channel := b.Client.Self.Channel
+		if name != "" {
+			channel = b.findChannel(name)
+		}
+		if channel == nil {
+			return TextMessage{}, fmt.Errorf("Channel %q Not Found", name)
+		}
+		channel.Send(body, to == MessageToTree)
+		if to == MessageToTree {
+			message.Trees = []string{channelPath(channel)}
+		} else {
+			message.Channels = []string{channelPath(channel)}
+		}
+	case MessageToUser:
+		user := b.Client.Users.Find(name)
+		if user == nil {
+			return TextMessage{}, fmt.Errorf("User %q Not Online", name)
+		}
+		user.Send(body)
+		message.Users = []string{user.Name}
+		message.Private = true
+	default:
+		return TextMessage{}, fmt.Errorf("Message Target %q Must be channel, tree or user", to)
+	}
+
+	message = recordMessage(message)
+	log.Printf("info: Message Sent to %s %q: %s\n", to, name, text)
+	return message, nil
+}
+
+// parseMessageCommand splits a message command of the form text, channel:name:text,
+// tree:name:text or user:name:text, plain text goes to the current channel
+func parseMessageCommand(command string) (to string, name string, text string) {
+	parts := strings.SplitN(command, ":", 3)
+	if len(parts) == 3 {
+		switch strings.ToLower(parts[0]) {
+		case MessageToChannel, MessageToTree, MessageToUser:
+			return strings.ToLower(parts[0]), strings.TrimSpace(parts[1]), parts[2]
+		}
+	}
+	return MessageToChannel, "", command
+}
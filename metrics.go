@@ -0,0 +1,177 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * metrics.go -> talkkonnect prometheus metrics in the text exposition format on the api listener
+ */
+
+package talkkonnect
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// counters are only ever incremented, gauges are read from the running state at scrape time
+var (
+	metricsMutex          sync.Mutex
+	metricReconnects      uint64
+	metricTxSessions      uint64
+	metricTxSeconds       float64
+	metricTxTimeouts      uint64
+	metricRxTalkSpurts    = make(map[string]uint64)
+	metricAudioPackets    uint64
+	metricBufferUnderruns uint64
+	metricTextMessages    uint64
+)
+
+func metricsCountReconnect() {
+	metricsMutex.Lock()
+	metricReconnects++
+	metricsMutex.Unlock()
+}
+
+func metricsCountTx(duration time.Duration) {
+	metricsMutex.Lock()
+	metricTxSessions++
+	metricTxSeconds += duration.Seconds()
+	metricsMutex.Unlock()
+}
+
+func metricsCountTxTimeout() {
+	metricsMutex.Lock()
+	metricTxTimeouts++
+	metricsMutex.Unlock()
+}
+
+func metricsCountTalkSpurt(user string) {
+	metricsMutex.Lock()
+	metricRxTalkSpurts[user]++
+	metricsMutex.Unlock()
+}
+
+func metricsCountAudioPacket() {
+	metricsMutex.Lock()
+	metricAudioPackets++
+	metricsMutex.Unlock()
+}
+
+func metricsCountBufferUnderrun() {
+	metricsMutex.Lock()
+	metricBufferUnderruns++
+	metricsMutex.Unlock()
+}
+
+func metricsCountTextMessage() {
+	metricsMutex.Lock()
+	metricTextMessages++
+	metricsMutex.Unlock()
+}
+
+func (b *Talkkonnect) apiMetrics(w http.ResponseWriter, r *http.Request) {
+	if !apiAllowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	if !APIMetrics {
+		http.Error(w, "metrics denied by config", http.StatusForbidden)
+		return
+	}
+
+	var out bytes.Buffer
+
+	connected := 0
+	if IsConnected {
+		connected = 1
+	}
+	transmitting := 0
+	if b.IsTransmitting {
+		transmitting = 1
+	}
+
+	metricWrite(&out, "talkkonnect_connected", "gauge", "1 when connected to a mumble server", fmt.Sprintf("{account=%q,server=%q} %d", metricLabel(b.Name), metricLabel(b.Address), connected))
+	metricWrite(&out, "talkkonnect_transmitting", "gauge", "1 while transmitting", fmt.Sprintf(" %d", transmitting))
+	metricWrite(&out, "talkkonnect_uptime_seconds", "gauge", "seconds since talkkonnect started", fmt.Sprintf(" %.0f", time.Since(StartTime).Seconds()))
+
+	metricsMutex.Lock()
+	metricWrite(&out, "talkkonnect_reconnect_attempts_total", "counter", "reconnect attempts made by the reconnect supervisor", fmt.Sprintf(" %d", metricReconnects))
+	metricWrite(&out, "talkkonnect_tx_sessions_total", "counter", "completed transmissions", fmt.Sprintf(" %d", metricTxSessions))
+	metricWrite(&out, "talkkonnect_tx_seconds_total", "counter", "total seconds spent transmitting", fmt.Sprintf(" %.3f", metricTxSeconds))
+	metricWrite(&out, "talkkonnect_tx_timeouts_total", "counter", "transmissions stopped by the tx timeout", fmt.Sprintf(" %d", metricTxTimeouts))
+	metricWrite(&out, "talkkonnect_audio_packets_received_total", "counter", "audio packets received from the mumble server", fmt.Sprintf(" %d", metricAudioPackets))
+	metricWrite(&out, "talkkonnect_buffer_underruns_total", "counter", "times the openal playback buffers ran dry", fmt.Sprintf(" %d", metricBufferUnderruns))
+	metricWrite(&out, "talkkonnect_text_messages_total", "counter", "text messages received", fmt.Sprintf(" %d", metricTextMessages))
+
+	users := make([]string, 0, len(metricRxTalkSpurts))
+	for user := range metricRxTalkSpurts {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	samples := make([]string, 0, len(users))
+	for _, user := range users {
+		samples = append(samples, fmt.Sprintf("{user=%q} %d", metricLabel(user), metricRxTalkSpurts[user]))
+	}
+	metricsMutex.Unlock()
+	metricWrite(&out, "talkkonnect_rx_talkspurts_total", "counter", "received talk spurts per speaking user", samples...)
+
+	var latency, reachable []string
+	for _, health := range getServerHealth() {
+		labels := fmt.Sprintf("{account=%q,server=%q}", metricLabel(health.Name), metricLabel(health.Server))
+		up := 0
+		if health.Reachable {
+			up = 1
+			latency = append(latency, fmt.Sprintf("%s %.6f", labels, health.LatencyMs/1000))
+		}
+		reachable = append(reachable, fmt.Sprintf("%s %d", labels, up))
+	}
+	metricWrite(&out, "talkkonnect_server_ping_latency_seconds", "gauge", "last ping latency to each default account", latency...)
+	metricWrite(&out, "talkkonnect_server_reachable", "gauge", "1 when the last ping to the account was answered", reachable...)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(out.Bytes())
+}
+
+// metricWrite writes one metric family, each sample is the label set (may be empty) and value
+func metricWrite(out *bytes.Buffer, name string, kind string, help string, samples ...string) {
+	fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	for _, sample := range samples {
+		fmt.Fprintf(out, "%s%s\n", name, sample)
+	}
+}
+
+// metricLabel strips characters %q would escape differently from the prometheus text format
+func metricLabel(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, value)
+}
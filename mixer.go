@@ -0,0 +1,206 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * mixer.go -> talkkonnect mixer of concurrent speakers into the single audio backend output
+ */
+
+package talkkonnect
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jdiderik/gumble/gumble"
+)
+
+const (
+	mixerInterval     = 20 * time.Millisecond
+	mixerFrameSamples = gumble.AudioSampleRate / 50 // 20ms of audio per mixed frame
+	mixerPrebuffer    = gumble.AudioSampleRate / 25 // 40ms queued before a talker is played, absorbs network jitter
+	mixerMaxQueued    = gumble.AudioSampleRate / 2  // older audio is dropped beyond 500ms so latency cannot grow
+)
+
+// per user gain from the mixer section survives reconnects and stream re-creation, 1 is unchanged
+var (
+	userGains      = make(map[string]float32)
+	userGainsMutex sync.Mutex
+)
+
+func getUserGain(user string) float32 {
+	userGainsMutex.Lock()
+	defer userGainsMutex.Unlock()
+	if gain, ok := userGains[user]; ok {
+		return gain
+	}
+	return 1
+}
+
+// setUserGains replaces all user gains at once, a reload never mixes old and new gains
+func setUserGains(gains map[string]float32) {
+	userGainsMutex.Lock()
+	defer userGainsMutex.Unlock()
+	userGains = gains
+}
+
+// mixerTrack is the decoded audio of one speaking user waiting to be mixed
+type mixerTrack struct {
+	user    string
+	pcm     []int16
+	playing bool
+}
+
+type audioMixer struct {
+	mutex   sync.Mutex
+	tracks  map[*mixerTrack]bool
+	clipped uint64
+	stop    chan bool
+	done    chan bool
+}
+
+func newAudioMixer() *audioMixer {
+	return &audioMixer{
+		tracks: make(map[*mixerTrack]bool),
+		stop:   make(chan bool),
+		done:   make(chan bool),
+	}
+}
+
+func (m *audioMixer) addTrack(user string) *mixerTrack {
+	track := &mixerTrack{user: user}
+	m.mutex.Lock()
+	m.tracks[track] = true
+	m.mutex.Unlock()
+	return track
+}
+
+func (m *audioMixer) removeTrack(track *mixerTrack) {
+	m.mutex.Lock()
+	delete(m.tracks, track)
+	m.mutex.Unlock()
+}
+
+// push queues decoded pcm of a track, the oldest audio is dropped when the track falls too far behind
+func (m *audioMixer) push(track *mixerTrack, pcm []int16) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	track.pcm = append(track.pcm, pcm...)
+	if over := len(track.pcm) - mixerMaxQueued; over > 0 {
+		track.pcm = track.pcm[over:]
+	}
+}
+
+// mix sums 20ms of every playing track with its gain and the master volume, it returns nil when
+// nobody is talking so no silence is queued to the backend
+func (m *audioMixer) mix() []int16 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var sum [mixerFrameSamples]float32
+	active := false
+
+	for track := range m.tracks {
+		if !track.playing && len(track.pcm) >= mixerPrebuffer {
+			track.playing = true
+		}
+		if !track.playing {
+			continue
+		}
+
+		n := len(track.pcm)
+		if n > mixerFrameSamples {
+			n = mixerFrameSamples
+		}
+		gain := getUserGain(track.user)
+		for i := 0; i < n; i++ {
+			sum[i] += float32(track.pcm[i]) * gain
+		}
+		track.pcm = track.pcm[n:]
+		active = true
+
+		// wait for a fresh prebuffer after the talker ran dry
+		if len(track.pcm) == 0 {
+			track.playing = false
+		}
+	}
+
+	if !active || OutputMuted {
+		return nil
+	}
+
+	volume := float32(OutputVolume) / 100
+	out := make([]int16, mixerFrameSamples)
+	for i, value := range sum {
+		value *= volume
+		switch {
+		case value > math.MaxInt16:
+			out[i] = math.MaxInt16
+			m.clipped++
+		case value < math.MinInt16:
+			out[i] = math.MinInt16
+			m.clipped++
+		default:
+			out[i] = int16(value)
+		}
+	}
+	return out
+}
+
+// run plays the mixed audio on the stream backend every 20ms until the mixer is closed
+func (m *audioMixer) run(backend AudioBackend) {
+	defer close(m.done)
+
+	ticker := time.NewTicker(mixerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			pcm := m.mix()
+			if pcm == nil {
+				continue
+			}
+			if !backend.Play(pcm) {
+				metricsCountBufferUnderrun()
+			}
+		}
+	}
+}
+
+func (m *audioMixer) close() {
+	close(m.stop)
+	<-m.done
+
+	m.mutex.Lock()
+	if m.clipped > 0 {
+		log.Printf("debug: Mixer Clipped %d Sample(s) While Mixing Overlapping Talkers\n", m.clipped)
+	}
+	m.mutex.Unlock()
+}
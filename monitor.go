@@ -0,0 +1,142 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * monitor.go -> talkkonnect receive only monitoring of extra channels with mumble channel listeners
+ */
+
+package talkkonnect
+
+import (
+	"log"
+	"sync"
+
+	"github.com/jdiderik/gumble/gumble"
+)
+
+// channel listeners (mumble 1.4 and later) are requested with fields of the UserState message
+// that the gumble protobuf definitions predate, so the message is encoded here
+const (
+	mumbleMessageUserState      = 9
+	userStateFieldSession       = 1
+	userStateFieldListeningAdd  = 21
+	userStateFieldListeningDrop = 22
+)
+
+var (
+	monitorMutex    sync.Mutex
+	monitorChannels = make(map[uint32]bool)
+)
+
+// monitoring reports whether audio from channel reaches us through a channel listener
+func monitoring(channel *gumble.Channel) bool {
+	if channel == nil {
+		return false
+	}
+	monitorMutex.Lock()
+	defer monitorMutex.Unlock()
+	return monitorChannels[channel.ID]
+}
+
+// startMonitor asks the server to send us the audio of the monitor channels in addition to the
+// audio of the channel we are in, servers older than 1.4 ignore the request
+func (b *Talkkonnect) startMonitor() {
+	monitorMutex.Lock()
+	defer monitorMutex.Unlock()
+
+	monitorChannels = make(map[uint32]bool)
+	if !MonitorEnabled || !IsConnected {
+		return
+	}
+
+	var add []uint32
+	for _, name := range MonitorChannels {
+		channel := b.findChannel(name)
+		if channel == nil {
+			log.Println("warn: Monitor Channel Not Found ", name)
+			continue
+		}
+		if monitorChannels[channel.ID] {
+			continue
+		}
+		monitorChannels[channel.ID] = true
+		add = append(add, channel.ID)
+		log.Println("info: Monitoring Channel ", channelPath(channel), " ID ", channel.ID)
+	}
+
+	if len(add) == 0 {
+		return
+	}
+
+	if err := b.Client.Conn.WritePacket(mumbleMessageUserState, userStateListening(b.Client.Self.Session, add, nil)); err != nil {
+		log.Println("error: Cannot Request Channel Listeners ", err)
+	}
+}
+
+// stopMonitor removes the channel listeners, used before the monitor channels change on reload
+func (b *Talkkonnect) stopMonitor() {
+	monitorMutex.Lock()
+	defer monitorMutex.Unlock()
+
+	var drop []uint32
+	for id := range monitorChannels {
+		drop = append(drop, id)
+	}
+	monitorChannels = make(map[uint32]bool)
+
+	if len(drop) == 0 || !IsConnected {
+		return
+	}
+
+	if err := b.Client.Conn.WritePacket(mumbleMessageUserState, userStateListening(b.Client.Self.Session, nil, drop)); err != nil {
+		log.Println("error: Cannot Remove Channel Listeners ", err)
+	}
+}
+
+// userStateListening encodes a UserState protobuf message that adds and removes channel listeners
+func userStateListening(session uint32, add []uint32, drop []uint32) []byte {
+	var data []byte
+	data = protoVarintField(data, userStateFieldSession, uint64(session))
+	for _, id := range add {
+		data = protoVarintField(data, userStateFieldListeningAdd, uint64(id))
+	}
+	for _, id := range drop {
+		data = protoVarintField(data, userStateFieldListeningDrop, uint64(id))
+	}
+	return data
+}
+
+func protoVarintField(data []byte, field int, value uint64) []byte {
+	data = protoVarint(data, uint64(field)<<3) // wire type 0, varint
+	return protoVarint(data, value)
+}
+
+func protoVarint(data []byte, value uint64) []byte {
+	for value >= 0x80 {
+		data = append(data, byte(value)|0x80)
+		value >>= 7
+	}
+	return append(data, byte(value))
+}
@@ -47,14 +47,15 @@ package talkkonnect
 
 import (
 	"crypto/tls"
+	"errors"
 	MQTT "github.com/eclipse/paho.mqtt.golang"
 	"log"
-	"os"
-	"os/signal"
-	"syscall"
+	"strings"
 	"time"
 )
 
+var mqttClient MQTT.Client
+
 func mqtttestpub() {
 
 	if MQTTAction != "pub" {
@@ -118,8 +119,17 @@ func (b *Talkkonnect) mqttsubscribe() {
 	log.Printf("debug: MQTT user        : %s\n", MQTTUser)
 	log.Printf("debug: MQTT password    : %s\n", MQTTPassword)
 	log.Printf("info: Subscribed topic : %s\n", MQTTTopic)
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	if err := b.mqttConnect(); err != nil {
+		log.Println("error: Cannot Connect to MQTT Broker ", MQTTBroker, " ", err)
+	}
+}
+
+// mqttConnect connects a new client to the configured broker and makes it the current client, the
+// paho client reconnects by itself from then on and subscribes again on every connect
+func (b *Talkkonnect) mqttConnect() error {
+	// the last will is fixed for the life of a client, so the client keeps the topic it was made for
+	topic, qos := MQTTTopic, byte(MQTTQos)
 
 	connOpts := MQTT.NewClientOptions().AddBroker(MQTTBroker).SetClientID(MQTTId).SetCleanSession(true)
 	if MQTTUser != "" {
@@ -131,26 +141,109 @@ func (b *Talkkonnect) mqttsubscribe() {
 	tlsConfig := &tls.Config{InsecureSkipVerify: true, ClientAuth: tls.NoClientCert}
 	connOpts.SetTLSConfig(tlsConfig)
 
+	// the broker marks talkkonnect offline if the connection is lost without a clean disconnect
+	connOpts.SetWill(topic+mqttStatusTopic, mqttOffline, qos, true)
+
 	connOpts.OnConnect = func(c MQTT.Client) {
-		if token := c.Subscribe(MQTTTopic, byte(MQTTQos), b.onMessageReceived); token.Wait() && token.Error() != nil {
-			panic(token.Error())
+		if token := c.Subscribe(topic, qos, b.onMessageReceived); token.Wait() && token.Error() != nil {
+			log.Println("error: Cannot Subscribe to MQTT Topic ", topic, token.Error())
+			return
 		}
+		mqttClient = c
+		go b.mqttOnConnect()
 	}
 
 	client := MQTT.NewClient(connOpts)
 	if token := client.Connect(); token.Wait() && token.Error() != nil {
-		panic(token.Error())
-	} else {
-		log.Printf("info: Connected to     : %s\n", MQTTBroker)
+		return token.Error()
 	}
 
-	<-c
+	log.Printf("info: Connected to     : %s\n", MQTTBroker)
+	mqttClient = client
+	return nil
+}
+
+// mqttDisconnect marks talkkonnect offline on statusTopic, a clean disconnect does not fire the
+// last will, and disconnects the client
+func mqttDisconnect(client MQTT.Client, statusTopic string) {
+	if client == nil || !client.IsConnected() {
+		return
+	}
+	token := client.Publish(statusTopic, byte(MQTTQos), true, mqttOffline)
+	if !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		log.Println("error: Cannot Publish MQTT Offline Status ", token.Error())
+	}
+	client.Disconnect(250)
+}
+
+// mqttPublish publishes payload to topic with the configured qos, retained messages are kept by the
+// broker and delivered to every new subscriber
+func mqttPublish(topic string, retained bool, payload []byte) error {
+	client := mqttClient
+	if client == nil || !client.IsConnected() {
+		return errors.New("Not Connected to MQTT Broker")
+	}
+
+	token := client.Publish(topic, byte(MQTTQos), retained, payload)
+	if !token.WaitTimeout(5 * time.Second) {
+		return errors.New("Timed Out Publishing to MQTT Topic " + topic)
+	}
+	return token.Error()
+}
+
+// mqttReload applies a reloaded mqtt section, broker, credential, topic or qos changes need a fresh
+// connection, the topic and qos because the last will on the status topic is set when connecting
+func (b *Talkkonnect) mqttReload(oldConfig *Document, newConfig *Document, oldTopic string) {
+	prev := oldConfig.Global.Software.MQTT
+	next := newConfig.Global.Software.MQTT
+
+	reconnect := prev.MQTTEnabled != next.MQTTEnabled || prev.MQTTBroker != next.MQTTBroker || prev.MQTTUser != next.MQTTUser || prev.MQTTPassword != next.MQTTPassword || prev.MQTTId != next.MQTTId || prev.MQTTTopic != next.MQTTTopic || prev.MQTTQos != next.MQTTQos
+	if !reconnect {
+		return
+	}
+
+	old := mqttClient
+	if old != nil && old.IsConnected() {
+		log.Println("info: Disconnecting From MQTT Broker ", prev.MQTTBroker)
+	}
+	mqttDisconnect(old, oldTopic+mqttStatusTopic)
+	mqttClient = nil
+
+	if !MQTTEnabled {
+		log.Println("info: MQTT Disabled in Reloaded Config")
+		return
+	}
+
+	// the new client publishes online and the current state to the new topic once connected
+	log.Println("info: Reconnecting to MQTT Broker ", MQTTBroker, " Topic ", MQTTTopic)
+	if err := b.mqttConnect(); err != nil {
+		// a mistyped or unreachable broker must not take the radio down, stay on the old broker
+		log.Println("error: Cannot Connect to MQTT Broker ", MQTTBroker, " ", err)
+		if old != nil && prev.MQTTEnabled {
+			log.Println("info: Reconnecting to Previous MQTT Broker ", prev.MQTTBroker)
+			if token := old.Connect(); token.Wait() && token.Error() != nil {
+				log.Println("error: Cannot Reconnect to Previous MQTT Broker ", token.Error())
+			} else {
+				mqttClient = old
+			}
+		}
+	}
 }
 
 func (b *Talkkonnect) onMessageReceived(client MQTT.Client, message MQTT.Message) {
 	log.Printf("info: Received MQTT message on topic: %s Payload: %s\n", message.Topic(), message.Payload())
 
-	switch string(message.Payload()) {
+	// commands that take an argument are sent as Command:argument e.g. Preset:12
+	command, argument := string(message.Payload()), ""
+	if i := strings.Index(command, ":"); i >= 0 {
+		command, argument = command[:i], command[i+1:]
+	}
+
+	// every command is acknowledged on the response topic with its error or the requested data
+	var err error
+	var data interface{}
+
+	switch command {
 	case "DisplayMenu":
 		log.Println("info: MQTT Display Menu Request Processed Successfully")
 		b.cmdDisplayMenu()
@@ -169,12 +262,21 @@ func (b *Talkkonnect) onMessageReceived(client MQTT.Client, message MQTT.Message
 	case "Unmute":
 		log.Println("info: MQTT Mute/UnMute Speaker Request Processed Successfully\n")
 		b.cmdMuteUnmute("unmute")
+	case "Volume":
+		log.Println("info: MQTT Set Volume Requested ", argument)
+		err = b.cmdVolume(argument)
+	case "Channel":
+		log.Println("info: MQTT Change Channel Requested ", argument)
+		err = b.cmdChannel(argument)
 	case "ListChannels":
 		log.Println("info: MQTT List Server Channels Request Processed Successfully\n")
 		b.cmdListServerChannels()
 	case "StartTransmitting":
 		log.Println("info: MQTT Start Transmitting Request Processed Successfully\n")
 		b.cmdStartTransmitting()
+		if !b.IsTransmitting {
+			err = errors.New("Cannot Start Transmitting")
+		}
 	case "StopTransmitting":
 		log.Println("info: MQTT Stop Transmitting Request Processed Successfully\n")
 		b.cmdStopTransmitting()
@@ -196,10 +298,53 @@ func (b *Talkkonnect) onMessageReceived(client MQTT.Client, message MQTT.Message
 	case "ShowUptime":
 		log.Println("info: MQTT Request Current Version Successfully\n")
 		b.cmdShowUptime()
+	case "ConnNextServer":
+		log.Println("info: MQTT Connect to Next Server Requested\n")
+		err = b.cmdConnNextServer()
+	case "ConnPreviousServer":
+		log.Println("info: MQTT Connect to Previous Server Requested\n")
+		err = b.cmdConnPreviousServer()
+	case "ReloadConfig":
+		log.Println("info: MQTT Reload XML Config Requested\n")
+		// a changed mqtt section disconnects this client, which waits for this handler to return
+		go func() {
+			mqttRespond(command, argument, b.cmdReloadConfig(), nil)
+		}()
+		return
+	case "Preset":
+		log.Println("info: MQTT Recall Preset Requested ", argument)
+		err = b.cmdPreset(argument)
+	case "Call":
+		log.Println("info: MQTT Private/Group Call Requested ", argument)
+		err = b.cmdCall(argument)
+	case "EndCall":
+		log.Println("info: MQTT End Call Requested")
+		b.cmdEndCall()
+	case "GPSPosition":
+		log.Println("info: MQTT GPS Position Requested")
+		b.cmdGPSPosition()
+		if GPSEnabled {
+			data = getGPSFix()
+		} else {
+			err = errors.New("GPS Disabled in XML config")
+		}
+	case "PanicSimulation":
+		log.Println("info: MQTT Panic Start/Stop Requested")
+		err = b.cmdPanicSimulation()
+	case "Panic":
+		log.Println("info: MQTT Panic Requested ", argument)
+		err = b.cmdPanic(argument)
+	case "SendMessage":
+		log.Println("info: MQTT Send Text Message Requested")
+		err = b.cmdSendMessage(argument)
 
 	// todo add other automation control for buttons, relays and leds here as needed in the future
 	default:
 		log.Printf("error: Undefined Command Received MQTT message on topic: %s Payload: %s\n", message.Topic(), message.Payload())
+		err = errors.New("Undefined Command " + command)
 	}
-	return
+
+	// paho delivers messages in order and cannot take the puback of the response while this
+	// handler blocks, so the response must not be waited on here
+	go mqttRespond(command, argument, err, data)
 }
@@ -0,0 +1,172 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * mqttbeacon.go -> talkkonnect aprs style position and status beacons published to mqtt
+ */
+
+package talkkonnect
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"time"
+)
+
+// how often the beacon routine checks whether a beacon is due
+const mqttBeaconPoll = time.Second
+
+type mqttBeaconStruct struct {
+	Time          time.Time `json:"time"`
+	Username      string    `json:"username"`
+	Ident         string    `json:"ident"`
+	Fix           bool      `json:"fix"`
+	Latitude      *float64  `json:"latitude,omitempty"`
+	Longitude     *float64  `json:"longitude,omitempty"`
+	AltitudeM     float64   `json:"altitudem,omitempty"`
+	SpeedKmh      float64   `json:"speedkmh"`
+	Heading       float64   `json:"heading"`
+	Satellites    int       `json:"satellites"`
+	Connected     bool      `json:"connected"`
+	Account       string    `json:"account"`
+	Server        string    `json:"server"`
+	Channel       string    `json:"channel,omitempty"`
+	Transmitting  bool      `json:"transmitting"`
+	UptimeSeconds int64     `json:"uptimeseconds"`
+	Reason        string    `json:"reason"`
+}
+
+// mqttBeaconTopic is the beacon topic, by default a beacon sub topic of the command topic
+func mqttBeaconTopic() string {
+	if MQTTBeaconTopic != "" {
+		return MQTTBeaconTopic
+	}
+	return MQTTTopic + "/beacon"
+}
+
+// smartBeaconRate is the beacon interval for a speed, slow below the slow speed, fast above the
+// fast speed and in between proportional to the speed as in aprs smart beaconing
+func smartBeaconRate(speedKmh float64) time.Duration {
+	switch {
+	case speedKmh <= MQTTBeaconSlowSpeedKmh:
+		return time.Duration(MQTTBeaconSlowIntervalSecs) * time.Second
+	case speedKmh >= MQTTBeaconFastSpeedKmh:
+		return time.Duration(MQTTBeaconFastIntervalSecs) * time.Second
+	default:
+		return time.Duration(float64(MQTTBeaconFastIntervalSecs)*MQTTBeaconFastSpeedKmh/speedKmh) * time.Second
+	}
+}
+
+// headingChange is the smallest angle between two headings in degrees
+func headingChange(from float64, to float64) float64 {
+	change := math.Mod(math.Abs(to-from), 360)
+	if change > 180 {
+		change = 360 - change
+	}
+	return change
+}
+
+// mqttBeaconRoutine publishes a beacon every intervalsecs, or with smart beaconing and a gps fix at
+// a rate depending on the speed and whenever the heading changed by more than the turn threshold
+func (b *Talkkonnect) mqttBeaconRoutine() {
+	var lastBeacon time.Time
+	var lastHeading float64
+
+	ticker := time.NewTicker(mqttBeaconPoll)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !MQTTEnabled || !MQTTBeaconEnabled || mqttClient == nil || !mqttClient.IsConnected() {
+			continue
+		}
+
+		fix := getGPSFix()
+		since := time.Since(lastBeacon)
+
+		var reason string
+		switch {
+		case lastBeacon.IsZero():
+			reason = "start"
+		case MQTTBeaconSmart && fix.Valid:
+			if since >= smartBeaconRate(fix.SpeedKmh) {
+				reason = "interval"
+			} else if fix.SpeedKmh > MQTTBeaconSlowSpeedKmh && since >= time.Duration(MQTTBeaconMinTurnSecs)*time.Second {
+				threshold := MQTTBeaconMinTurnAngle + MQTTBeaconTurnSlope/fix.SpeedKmh
+				if headingChange(lastHeading, fix.Course) > threshold {
+					reason = "turn"
+				}
+			}
+		case since >= time.Duration(MQTTBeaconIntervalSecs)*time.Second:
+			reason = "interval"
+		}
+
+		if reason == "" {
+			continue
+		}
+
+		if err := b.mqttBeacon(fix, reason); err != nil {
+			log.Println("error: Cannot Publish MQTT Beacon ", err)
+			continue
+		}
+		lastBeacon, lastHeading = time.Now(), fix.Course
+	}
+}
+
+// mqttBeacon publishes one retained beacon so a new subscriber gets the last known state at once
+func (b *Talkkonnect) mqttBeacon(fix GPSFix, reason string) error {
+	beacon := mqttBeaconStruct{
+		Time:          time.Now(),
+		Username:      b.Username,
+		Ident:         b.Ident,
+		Fix:           fix.Valid,
+		Satellites:    fix.Satellites,
+		Connected:     IsConnected,
+		Account:       b.Name,
+		Server:        b.Address,
+		Transmitting:  b.IsTransmitting,
+		UptimeSeconds: int64(time.Since(StartTime).Seconds()),
+		Reason:        reason,
+	}
+
+	if latitude, longitude, known := lastPosition(); known {
+		beacon.Latitude, beacon.Longitude = &latitude, &longitude
+		beacon.AltitudeM = fix.AltitudeM
+	}
+	if fix.Valid {
+		beacon.SpeedKmh, beacon.Heading = fix.SpeedKmh, fix.Course
+	}
+	if IsConnected && b.Client != nil && b.Client.Self != nil && b.Client.Self.Channel != nil {
+		beacon.Channel = channelPath(b.Client.Self.Channel)
+	}
+
+	payload, err := json.Marshal(beacon)
+	if err != nil {
+		return err
+	}
+
+	log.Println("debug: MQTT Beacon ", reason, " to ", mqttBeaconTopic())
+	return mqttPublish(mqttBeaconTopic(), true, payload)
+}
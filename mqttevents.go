@@ -0,0 +1,190 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * mqttevents.go -> talkkonnect state, events and command responses published to mqtt sub topics
+ */
+
+package talkkonnect
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// sub topics of mqtttopic that talkkonnect publishes to
+const (
+	mqttStatusTopic   = "/status"
+	mqttChannelTopic  = "/channel"
+	mqttTalkerTopic   = "/talker"
+	mqttTxTopic       = "/tx"
+	mqttVolumeTopic   = "/volume"
+	mqttMessageTopic  = "/message"
+	mqttResponseTopic = "/response"
+	mqttOnline        = "online"
+	mqttOffline       = "offline"
+)
+
+type mqttChannelStruct struct {
+	Time      time.Time `json:"time"`
+	Connected bool      `json:"connected"`
+	Account   string    `json:"account"`
+	Server    string    `json:"server"`
+	Channel   string    `json:"channel,omitempty"`
+	ChannelID uint32    `json:"channelid"`
+	Path      string    `json:"path,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+type mqttTalkerStruct struct {
+	Time      time.Time `json:"time"`
+	Talking   bool      `json:"talking"`
+	User      string    `json:"user"`
+	Channel   string    `json:"channel,omitempty"`
+	Monitored bool      `json:"monitored,omitempty"`
+}
+
+type mqttTxStruct struct {
+	Time            time.Time `json:"time"`
+	Transmitting    bool      `json:"transmitting"`
+	Channel         string    `json:"channel,omitempty"`
+	Call            string    `json:"call,omitempty"`
+	DurationSeconds float64   `json:"durationseconds,omitempty"`
+	TimedOut        bool      `json:"timedout,omitempty"`
+}
+
+type mqttResponseStruct struct {
+	Time     time.Time   `json:"time"`
+	Command  string      `json:"command"`
+	Argument string      `json:"argument,omitempty"`
+	Status   string      `json:"status"`
+	Error    string      `json:"error,omitempty"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+// mqttPublishJSON publishes data as json to a sub topic of mqtttopic
+func mqttPublishJSON(subTopic string, retained bool, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Println("error: Cannot Encode MQTT Message ", err)
+		return
+	}
+	if err := mqttPublish(MQTTTopic+subTopic, retained, payload); err != nil {
+		log.Println("error: Cannot Publish to MQTT Topic ", MQTTTopic+subTopic, err)
+	}
+}
+
+// mqttRespond acknowledges a command received over mqtt on the response topic
+func mqttRespond(command string, argument string, err error, data interface{}) {
+	response := mqttResponseStruct{Time: time.Now(), Command: command, Argument: argument, Status: "ok", Data: data}
+	if err != nil {
+		response.Status, response.Error = "error", err.Error()
+	}
+	mqttPublishJSON(mqttResponseTopic, false, response)
+}
+
+// mqttOnConnect publishes the retained online status and the current state after every connect to
+// the broker, the broker publishes offline (the last will) if the connection is lost
+func (b *Talkkonnect) mqttOnConnect() {
+	if err := mqttPublish(MQTTTopic+mqttStatusTopic, true, []byte(mqttOnline)); err != nil {
+		log.Println("error: Cannot Publish MQTT Online Status ", err)
+	}
+	b.mqttPublishChannel("")
+	mqttPublishJSON(mqttTxTopic, true, mqttTxStruct{Time: time.Now(), Transmitting: b.IsTransmitting})
+	mqttPublishJSON(mqttVolumeTopic, true, eventVolumeData{Volume: OutputVolume, Muted: OutputMuted})
+	if HomeAssistantEnabled {
+		b.homeAssistantDiscovery()
+	}
+}
+
+// mqttPublishOffline marks talkkonnect offline before a clean shutdown, when the last will does not apply
+func mqttPublishOffline() {
+	if !MQTTEnabled {
+		return
+	}
+	if err := mqttPublish(MQTTTopic+mqttStatusTopic, true, []byte(mqttOffline)); err != nil {
+		log.Println("error: Cannot Publish MQTT Offline Status ", err)
+	}
+}
+
+func (b *Talkkonnect) mqttPublishChannel(reason string) {
+	state := mqttChannelStruct{Time: time.Now(), Connected: IsConnected, Account: b.Name, Server: b.Address, Reason: reason}
+	if IsConnected && b.Client != nil && b.Client.Self != nil && b.Client.Self.Channel != nil {
+		state.Channel = b.Client.Self.Channel.Name
+		state.ChannelID = b.Client.Self.Channel.ID
+		state.Path = channelPath(b.Client.Self.Channel)
+	}
+	mqttPublishJSON(mqttChannelTopic, true, state)
+}
+
+// mqttEventBridge forwards the events of the live event feed to the mqtt sub topics, it runs for
+// the life of talkkonnect and drops events while mqtt is disabled or not connected
+func (b *Talkkonnect) mqttEventBridge() {
+	events := subscribeEvents()
+	defer unsubscribeEvents(events)
+
+	for event := range events {
+		if !MQTTEnabled || mqttClient == nil || !mqttClient.IsConnected() {
+			continue
+		}
+
+		switch data := event.Data.(type) {
+		case eventServerData:
+			switch event.Type {
+			case EventConnected:
+				b.mqttPublishChannel("connected")
+				// the channel select of home assistant lists the channels of this server
+				if HomeAssistantEnabled {
+					b.homeAssistantDiscovery()
+				}
+			case EventDisconnected:
+				b.mqttPublishChannel(data.Reason)
+			}
+		case eventUserData:
+			switch event.Type {
+			case EventUserMoved:
+				if IsConnected && b.Client != nil && b.Client.Self != nil && data.Session == b.Client.Self.Session {
+					b.mqttPublishChannel("moved")
+				}
+			case EventTalkerStart, EventTalkerStop:
+				mqttPublishJSON(mqttTalkerTopic, true, mqttTalkerStruct{Time: event.Time, Talking: event.Type == EventTalkerStart, User: data.User, Channel: data.Channel, Monitored: data.Monitored})
+			}
+		case eventTxData:
+			switch event.Type {
+			case EventTxStart:
+				mqttPublishJSON(mqttTxTopic, true, mqttTxStruct{Time: event.Time, Transmitting: true, Channel: data.Channel, Call: data.Call})
+			case EventTxStop:
+				mqttPublishJSON(mqttTxTopic, true, mqttTxStruct{Time: event.Time, Transmitting: false, Channel: data.Channel, Call: data.Call, DurationSeconds: data.DurationSeconds})
+			case EventTxTimeout:
+				mqttPublishJSON(mqttTxTopic, true, mqttTxStruct{Time: event.Time, Transmitting: false, Channel: data.Channel, DurationSeconds: data.DurationSeconds, TimedOut: true})
+			}
+		case eventMessageData:
+			mqttPublishJSON(mqttMessageTopic, false, data)
+		case eventVolumeData:
+			mqttPublishJSON(mqttVolumeTopic, true, data)
+		}
+	}
+}
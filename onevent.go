@@ -60,24 +60,45 @@ func (b *Talkkonnect) OnConnect(e *gumble.ConnectEvent) {
 		b.ChangeChannel(b.ChannelName)
 		prevChannelID = b.Client.Self.Channel.ID
 	}
+
+	b.startMonitor()
+
+	publishEvent(EventConnected, eventServerData{Account: b.Name, Server: b.Address, Channel: b.Client.Self.Channel.Name})
+
+	b.saveState()
 }
 
 func (b *Talkkonnect) OnDisconnect(e *gumble.DisconnectEvent) {
+	// the voice target of a call does not survive the connection
+	b.endCall("disconnected")
+	// nor does a transmission, its watchdog must not lock out tx after the link is gone
+	b.txWatchdogStop()
+
+	// disconnects we asked for ourselves (reconnect, account change) are handled by the caller
+	if e.Type == gumble.DisconnectUser {
+		log.Println("info: Disconnected From ", b.Address, " On Request")
+		return
+	}
 
 	var reason string
 
 	switch e.Type {
 	case gumble.DisconnectError:
 		reason = "connection error"
+	case gumble.DisconnectKicked:
+		reason = "kicked"
+	case gumble.DisconnectBanned:
+		reason = "banned"
+	default:
+		reason = "disconnected by server"
 	}
 
 	IsConnected = false
 
+	publishEvent(EventDisconnected, eventServerData{Account: b.Name, Server: b.Address, Reason: reason})
+
 	if !ServerHop {
-		log.Println("alert: Attempting Reconnect in 10 seconds...")
-		log.Println("alert: Connection to ", b.Address, "disconnected")
-		log.Println("alert: Disconnection Reason ", reason)
-		b.ReConnect()
+		b.startReconnect(reason)
 	}
 
 }
@@ -102,6 +123,11 @@ func (b *Talkkonnect) OnTextMessage(e *gumble.TextMessageEvent) {
 
 	log.Println(fmt.Sprintf("info: Message ("+strconv.Itoa(len(message))+") from %v %v\n", sender, message))
 
+	// the history and the event feed keep the whole message, only the screen gets the short one
+	received := recordMessage(receivedMessage(e))
+	publishEvent(EventTextMessage, eventMessageData{ID: received.ID, Sender: received.Sender, Channels: received.Channels, Trees: received.Trees, Private: received.Private, Message: received.Message})
+	metricsCountTextMessage()
+
 	if EventSoundEnabled {
 		err := playWavLocal(EventMessageSoundFilenameAndPath, 100)
 		if err != nil {
@@ -153,6 +179,31 @@ func (b *Talkkonnect) OnUserChange(e *gumble.UserChangeEvent) {
 		}
 	}
 
+	userData := eventUserData{User: e.User.Name, Session: e.User.Session}
+	if e.User.Channel != nil {
+		userData.Channel = e.User.Channel.Name
+		userData.ChannelID = e.User.Channel.ID
+	}
+
+	// remember the channel we moved to so a reconnect or restart rejoins it, channels the
+	// scanner passes through are not remembered
+	if IsConnected && e.User == e.Client.Self && e.Type.Has(gumble.UserChangeChannel) && e.User.Channel != nil {
+		channelNavArrived()
+		if !scanActive() {
+			b.ChannelName = channelPath(e.User.Channel)
+			b.saveState()
+		}
+	}
+
+	switch {
+	case e.Type.Has(gumble.UserChangeConnected):
+		publishEvent(EventUserJoined, userData)
+	case e.Type.Has(gumble.UserChangeDisconnected) || e.Type.Has(gumble.UserChangeKicked) || e.Type.Has(gumble.UserChangeBanned):
+		publishEvent(EventUserLeft, userData)
+	case e.Type.Has(gumble.UserChangeChannel):
+		publishEvent(EventUserMoved, userData)
+	}
+
 	b.ParticipantLEDUpdate(true)
 }
 
@@ -165,30 +216,8 @@ func (b *Talkkonnect) OnPermissionDenied(e *gumble.PermissionDeniedEvent) {
 	case gumble.PermissionDeniedPermission:
 		info = "insufficient permissions"
 
-		// Set Upper Boundary
-		if prevButtonPress == "ChannelUp" && b.Client.Self.Channel.ID == maxchannelid {
-			log.Println("error: Can't Increment Channel Maximum Channel Reached")
-		}
-
-		// Set Lower Boundary
-		if prevButtonPress == "ChannelDown" && currentChannelID == 0 {
-			log.Println("error: Can't Increment Channel Minimum Channel Reached")
-		}
-
-		// Implement Seek Up Until Permissions are Sufficient for User to Join Channel whilst avoiding all null channels
-		if prevButtonPress == "ChannelUp" && b.Client.Self.Channel.ID+1 < maxchannelid {
-			prevChannelID++
-			b.ChannelUp()
-			LcdText[1] = b.Client.Self.Channel.Name + " (" + strconv.Itoa(len(b.Client.Self.Channel.Users)) + " Users)"
-		}
-
-		// Implement Seek Dwn Until Permissions are Sufficient for User to Join Channel whilst avoiding all null channels
-		if prevButtonPress == "ChannelDown" && int(b.Client.Self.Channel.ID) > 0 {
-			prevChannelID--
-			b.ChannelDown()
-			LcdText[1] = b.Client.Self.Channel.Name + " (" + strconv.Itoa(len(b.Client.Self.Channel.Users)) + " Users)"
-		}
-
+		// channel up/down skips channels we are not allowed to enter
+		b.channelNavDenied()
 	case gumble.PermissionDeniedSuperUser:
 		info = "cannot modify SuperUser"
 	case gumble.PermissionDeniedInvalidChannelName:
@@ -208,6 +237,12 @@ func (b *Talkkonnect) OnPermissionDenied(e *gumble.PermissionDeniedEvent) {
 	}
 
 	log.Println("error: Permission denied  ", info)
+
+	permissionData := eventPermissionData{Reason: info}
+	if e.Channel != nil {
+		permissionData.Channel = e.Channel.Name
+	}
+	publishEvent(EventPermissionDenied, permissionData)
 }
 
 func (b *Talkkonnect) OnChannelChange(e *gumble.ChannelChangeEvent) {
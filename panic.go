@@ -0,0 +1,197 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * panic.go -> talkkonnect panic/emergency mode, alert tone, message, email and open mic until cancelled
+ */
+
+package talkkonnect
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	panicMutex   sync.Mutex
+	panicStop    chan bool
+	panicStarted time.Time
+)
+
+func panicActive() bool {
+	panicMutex.Lock()
+	defer panicMutex.Unlock()
+	return panicStop != nil
+}
+
+// startPanic raises the alarm, the alert repeats every PRepeatSecs until cancelPanic
+func (b *Talkkonnect) startPanic() error {
+	if !PEnabled {
+		return errors.New("Panic Function Disabled in XML config")
+	}
+	if !IsConnected {
+		return errors.New("Not Connected to a Server")
+	}
+
+	panicMutex.Lock()
+	if panicStop != nil {
+		panicMutex.Unlock()
+		return errors.New("Panic Already Active")
+	}
+	stop := make(chan bool)
+	panicStop = stop
+	panicStarted = time.Now()
+	panicMutex.Unlock()
+
+	log.Println("alert: Panic Activated")
+
+	// help must reach the channel, not whoever a private call was going to
+	b.endCall("panic")
+
+	latitude, longitude, known := lastPosition()
+	data := eventPanicData{Active: true, Channel: b.Client.Self.Channel.Name}
+	if known {
+		data.Latitude, data.Longitude = latitude, longitude
+	}
+	publishEvent(EventPanic, data)
+
+	if PMailEnabled {
+		go func() {
+			if err := sendEmail(EmailSubject, b.panicText()+"\r\n"+EmailMessage); err != nil {
+				log.Println("error: Cannot Send Panic Email ", err)
+			} else {
+				log.Println("info: Panic Email Sent to ", EmailReceiver)
+			}
+		}()
+	}
+
+	go b.panicRoutine(stop)
+	return nil
+}
+
+// cancelPanic ends panic mode, closing the mic if it is still open for the panic
+func (b *Talkkonnect) cancelPanic() {
+	panicMutex.Lock()
+	if panicStop == nil {
+		panicMutex.Unlock()
+		return
+	}
+	close(panicStop)
+	panicStop = nil
+	active := time.Since(panicStarted)
+	panicMutex.Unlock()
+
+	log.Printf("alert: Panic Cancelled After %v\n", active.Round(time.Second))
+	if IsConnected {
+		b.panicMessage(fmt.Sprintf("Panic Cancelled by %s", b.Username))
+	}
+	publishEvent(EventPanic, eventPanicData{Active: false, DurationSeconds: active.Seconds()})
+}
+
+func (b *Talkkonnect) togglePanic() error {
+	if panicActive() {
+		b.cancelPanic()
+		return nil
+	}
+	return b.startPanic()
+}
+
+func (b *Talkkonnect) panicRoutine(stop chan bool) {
+	first := true
+	for {
+		if IsConnected {
+			b.panicAlert(first, stop)
+			first = false
+		}
+
+		if PRepeatSecs <= 0 {
+			<-stop
+			return
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(time.Duration(PRepeatSecs) * time.Second):
+		}
+	}
+}
+
+// panicAlert plays the alert tone into the channel and sends the panic message, after the first
+// alert the mic is opened for PTxlockTimeOutSecs so the user can talk without ptt
+func (b *Talkkonnect) panicAlert(first bool, stop chan bool) {
+	if b.IsTransmitting {
+		b.TransmitStop(false)
+	}
+
+	if PFilenameAndPath != "" && b.Stream != nil {
+		b.Stream.playIntoStream(PFilenameAndPath, PVolume)
+	}
+
+	b.panicMessage(b.panicText())
+
+	if !first || !PTxLockEnabled || PTxlockTimeOutSecs <= 0 {
+		return
+	}
+
+	log.Printf("alert: Panic Mic Open for %d Seconds\n", PTxlockTimeOutSecs)
+	b.TransmitStart()
+	select {
+	case <-stop:
+	case <-time.After(time.Duration(PTxlockTimeOutSecs) * time.Second):
+	}
+	if b.IsTransmitting {
+		b.TransmitStop(false)
+	}
+}
+
+// panicText is the panic message with the ident and the last known position when configured
+func (b *Talkkonnect) panicText() string {
+	text := PMessage
+	if PSendIdent {
+		text += fmt.Sprintf(" My Username is %s and Ident is %s", b.Username, b.Ident)
+	}
+	if PSendGpsLocation {
+		if latitude, longitude, known := lastPosition(); known {
+			text += fmt.Sprintf(" My Position is %.6f,%.6f %s", latitude, longitude, googleMapsURL(latitude, longitude))
+		} else {
+			text += " My Position is Unknown (No GPS Fix)"
+		}
+	}
+	return text
+}
+
+func (b *Talkkonnect) panicMessage(text string) {
+	to := MessageToChannel
+	if PRecursive {
+		to = MessageToTree
+	}
+	if _, err := b.sendTextMessage(to, "", text); err != nil {
+		log.Println("error: Cannot Send Panic Message ", err)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * presets.go -> talkkonnect radio style channel memories, numbered presets of account and channel
+ */
+
+package talkkonnect
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// digits typed on the keyboard are collected until two are entered or the keypad is left idle
+const presetKeyTimeout = 1500 * time.Millisecond
+
+// ChannelPreset is one numbered memory, an empty account means the account currently connected
+type ChannelPreset struct {
+	Number  int    `json:"number"`
+	Name    string `json:"name"`
+	Account string `json:"account,omitempty"`
+	Channel string `json:"channel"`
+}
+
+var (
+	presetKeyMutex  sync.Mutex
+	presetKeyDigits string
+	presetKeyTimer  *time.Timer
+)
+
+// getPresets returns the configured presets ordered by number
+func getPresets() []ChannelPreset {
+	presets := make([]ChannelPreset, 0, len(Presets))
+	for _, preset := range Presets {
+		presets = append(presets, preset)
+	}
+	sort.Slice(presets, func(i, j int) bool { return presets[i].Number < presets[j].Number })
+	return presets
+}
+
+// recallPreset moves to the channel of the preset, connecting to its account first when that is
+// not the account in use
+func (b *Talkkonnect) recallPreset(number int) error {
+	preset, ok := Presets[number]
+	if !ok {
+		return fmt.Errorf("Preset %d Not Defined in XML config", number)
+	}
+
+	log.Printf("info: Recalling Preset %d %s (Account %q Channel %q)\n", preset.Number, preset.Name, preset.Account, preset.Channel)
+
+	if preset.Account != "" && (preset.Account != b.Name || !IsConnected) {
+		index := -1
+		for i, account := range getAccounts() {
+			if account.Name == preset.Account {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return fmt.Errorf("Preset %d Account %s is Not a Default Account", number, preset.Account)
+		}
+
+		if err := b.connectAccount(index); err != nil {
+			return err
+		}
+	}
+
+	if !IsConnected {
+		return fmt.Errorf("Preset %d Cannot Connect to Account %s at %s", number, b.Name, b.Address)
+	}
+
+	channel := b.findChannel(preset.Channel)
+	if channel == nil {
+		return fmt.Errorf("Preset %d Channel %q Not Found on %s", number, preset.Channel, b.Name)
+	}
+
+	if channel != b.Client.Self.Channel {
+		b.Client.Self.Move(channel)
+		prevChannelID = channel.ID
+	}
+
+	announce(fmt.Sprintf("Preset %d %s", preset.Number, preset.Name))
+	return nil
+}
+
+// presetKeyDigit collects a preset number typed on the keyboard, the preset is recalled after the
+// second digit or when no further digit follows within presetKeyTimeout
+func (b *Talkkonnect) presetKeyDigit(digit rune) {
+	presetKeyMutex.Lock()
+	defer presetKeyMutex.Unlock()
+
+	if presetKeyTimer != nil {
+		presetKeyTimer.Stop()
+		presetKeyTimer = nil
+	}
+
+	presetKeyDigits += string(digit)
+	log.Println("info: Preset Number Entry ", presetKeyDigits)
+
+	if len(presetKeyDigits) >= 2 {
+		digits := presetKeyDigits
+		presetKeyDigits = ""
+		go b.cmdPreset(digits)
+		return
+	}
+
+	presetKeyTimer = time.AfterFunc(presetKeyTimeout, func() {
+		presetKeyMutex.Lock()
+		digits := presetKeyDigits
+		presetKeyDigits = ""
+		presetKeyTimer = nil
+		presetKeyMutex.Unlock()
+
+		if digits != "" {
+			b.cmdPreset(digits)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * reconnect.go -> talkkonnect reconnect supervisor with exponential backoff, jitter and account failover
+ */
+
+package talkkonnect
+
+import (
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// reconnectStatus is what the supervisor is doing right now, it is reported by the api
+type reconnectStatus struct {
+	Active    bool       `json:"active"`
+	Attempt   int        `json:"attempt"`
+	NextRetry *time.Time `json:"nextretry,omitempty"`
+	LastError string     `json:"lasterror,omitempty"`
+	Failovers int        `json:"failovers"`
+	Account   string     `json:"account,omitempty"`
+}
+
+var (
+	reconnectState reconnectStatus
+	reconnectMutex sync.Mutex
+)
+
+func getReconnectStatus() reconnectStatus {
+	reconnectMutex.Lock()
+	defer reconnectMutex.Unlock()
+	return reconnectState
+}
+
+// startReconnect starts the reconnect supervisor unless it is already running, it keeps dialing
+// until the client is connected again, someone else connected it or talkkonnect is shutting down
+func (b *Talkkonnect) startReconnect(reason string) {
+	reconnectMutex.Lock()
+	if reconnectState.Active {
+		reconnectState.LastError = reason
+		reconnectMutex.Unlock()
+		return
+	}
+	reconnectState.Active = true
+	reconnectState.Attempt = 0
+	reconnectState.LastError = reason
+	reconnectState.Account = b.Name
+	reconnectMutex.Unlock()
+
+	log.Println("alert: Connection to ", b.Address, " Lost, Reason ", reason, " Starting Reconnect Supervisor")
+
+	go b.reconnectSupervisor()
+}
+
+func (b *Talkkonnect) reconnectSupervisor() {
+	delay := time.Duration(ReconnectInitialDelaySecs) * time.Second
+	maxDelay := time.Duration(ReconnectMaxDelaySecs) * time.Second
+	failures := 0
+
+	defer func() {
+		reconnectMutex.Lock()
+		reconnectState.Active = false
+		reconnectState.NextRetry = nil
+		reconnectMutex.Unlock()
+	}()
+
+	for {
+		wait := reconnectJitter(delay)
+
+		reconnectMutex.Lock()
+		reconnectState.Attempt++
+		nextRetry := time.Now().Add(wait)
+		reconnectState.NextRetry = &nextRetry
+		attempt := reconnectState.Attempt
+		reconnectMutex.Unlock()
+
+		log.Printf("alert: Reconnect Attempt %d to %s in %v\n", attempt, b.Address, wait.Round(time.Millisecond))
+		time.Sleep(wait)
+
+		if IsConnected {
+			log.Println("info: Reconnect Supervisor Stopped, Already Connected to ", b.Address)
+			return
+		}
+
+		if ServerHop {
+			log.Println("info: Reconnect Supervisor Stopped, Server Change or Shutdown in Progress")
+			return
+		}
+
+		metricsCountReconnect()
+		err := b.reconnectAttempt(failures)
+		if err == nil {
+			log.Printf("info: Reconnected to %s After %d Attempt(s)\n", b.Address, attempt)
+			return
+		}
+
+		failures++
+		ConnectAttempts = failures
+
+		reconnectMutex.Lock()
+		reconnectState.LastError = err.Error()
+		reconnectMutex.Unlock()
+
+		log.Printf("error: Reconnect Attempt %d to %s Failed %v\n", attempt, b.Address, err)
+
+		delay = time.Duration(float64(delay) * ReconnectMultiplier)
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+// reconnectAttempt dials the current account, or the next default account once failoverafter
+// consecutive attempts have failed
+func (b *Talkkonnect) reconnectAttempt(failures int) error {
+	accountMutex.Lock()
+	defer accountMutex.Unlock()
+
+	if list := getAccounts(); ReconnectFailoverAfter > 0 && failures > 0 && failures%ReconnectFailoverAfter == 0 && len(list) > 1 {
+		next := (AccountIndex + 1) % len(list)
+		log.Printf("warn: %d Failed Reconnect Attempts, Failing Over From %s to Account %s [%d]\n", failures, b.Name, list[next].Name, next)
+		if err := b.loadAccount(next); err != nil {
+			return err
+		}
+
+		reconnectMutex.Lock()
+		reconnectState.Failovers++
+		reconnectState.Account = b.Name
+		reconnectMutex.Unlock()
+	}
+
+	if b.Stream != nil {
+		b.Stream.Destroy()
+		b.Stream = nil
+	}
+
+	return b.dial()
+}
+
+// reconnectJitter spreads the delay by +/- the configured jitter fraction so a fleet of radios
+// losing the same cell does not hammer the server in lock step
+func reconnectJitter(delay time.Duration) time.Duration {
+	if ReconnectJitter <= 0 {
+		return delay
+	}
+	spread := float64(delay) * ReconnectJitter
+	return time.Duration(float64(delay) - spread + rand.Float64()*2*spread)
+}
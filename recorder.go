@@ -0,0 +1,364 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * recorder.go -> talkkonnect recording of received traffic and transmitted mic audio to wav files
+ */
+
+package talkkonnect
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jdiderik/gumble/gumble"
+)
+
+// recording state, rx files are keyed by the speaking user so overlapping talkers get a file each
+var (
+	recordMutex     sync.Mutex
+	recordTraffic   bool
+	recordMic       bool
+	recordRxFiles   = make(map[string]*wavWriter)
+	recordTxFile    *wavWriter
+	recordRotateMux sync.Mutex
+)
+
+// wavWriter writes 16 bit mono pcm at the mumble sample rate, the sizes in the header are
+// filled in when the file is closed
+type wavWriter struct {
+	file    *os.File
+	samples uint32
+	started time.Time
+}
+
+func newWavWriter(path string) (*wavWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &wavWriter{file: file, started: time.Now()}
+	if err := w.writeHeader(); err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *wavWriter) writeHeader() error {
+	const channels, bitsPerSample = 1, 16
+	dataSize := w.samples * 2
+
+	header := make([]byte, 44)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], 36+dataSize)
+	copy(header[8:], "WAVE")
+	copy(header[12:], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], 1)
+	binary.LittleEndian.PutUint16(header[22:], channels)
+	binary.LittleEndian.PutUint32(header[24:], gumble.AudioSampleRate)
+	binary.LittleEndian.PutUint32(header[28:], gumble.AudioSampleRate*channels*bitsPerSample/8)
+	binary.LittleEndian.PutUint16(header[32:], channels*bitsPerSample/8)
+	binary.LittleEndian.PutUint16(header[34:], bitsPerSample)
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], dataSize)
+
+	_, err := w.file.WriteAt(header, 0)
+	return err
+}
+
+func (w *wavWriter) write(pcm []int16) error {
+	buf := make([]byte, len(pcm)*2)
+	for i, value := range pcm {
+		binary.LittleEndian.PutUint16(buf[i*2:], uint16(value))
+	}
+	if _, err := w.file.WriteAt(buf, 44+int64(w.samples)*2); err != nil {
+		return err
+	}
+	w.samples += uint32(len(pcm))
+	return nil
+}
+
+func (w *wavWriter) close() error {
+	err := w.writeHeader()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// recordFileName builds 20060102-150405_rx_user_channel.wav in the save path
+func recordFileName(direction string, user string, channel string) string {
+	name := fmt.Sprintf("%s_%s_%s_%s.wav", time.Now().Format("20060102-150405.000"), direction, cleanstring(user), cleanstring(channel))
+	return filepath.Join(AudioRecordSavePath, name)
+}
+
+// startRecording turns recording on in traffic, mic or combo mode
+func startRecording(mode string) {
+	if !AudioRecordEnabled {
+		log.Println("warn: Audio Recording Function Disabled in XML config")
+		return
+	}
+
+	if err := os.MkdirAll(AudioRecordSavePath, 0755); err != nil {
+		log.Println("error: Cannot Create Audio Recording Directory ", err)
+		return
+	}
+
+	recordMutex.Lock()
+	recordTraffic = mode == "traffic" || mode == "combo"
+	recordMic = mode == "mic" || mode == "combo"
+	recordMutex.Unlock()
+
+	log.Printf("info: Audio Recording Started Mode %s Saving to %s\n", mode, AudioRecordSavePath)
+}
+
+// stopRecording turns recording off and closes every open file
+func stopRecording() {
+	recordMutex.Lock()
+	recordTraffic = false
+	recordMic = false
+	for user := range recordRxFiles {
+		recordCloseLocked(recordRxFiles[user])
+		delete(recordRxFiles, user)
+	}
+	if recordTxFile != nil {
+		recordCloseLocked(recordTxFile)
+		recordTxFile = nil
+	}
+	recordMutex.Unlock()
+
+	log.Println("info: Audio Recording Stopped")
+}
+
+// toggleRecording starts recording in mode, or stops it if that mode is already recording
+func toggleRecording(mode string) {
+	recordMutex.Lock()
+	active := (mode == "traffic" && recordTraffic && !recordMic) || (mode == "mic" && recordMic && !recordTraffic) || (mode == "combo" && recordTraffic && recordMic)
+	recordMutex.Unlock()
+
+	if active {
+		stopRecording()
+		return
+	}
+	stopRecording()
+	startRecording(mode)
+}
+
+// recordRxPacket appends a received audio packet to the file of the speaking user
+func recordRxPacket(user string, channel string, pcm []int16) {
+	recordMutex.Lock()
+	defer recordMutex.Unlock()
+
+	if !recordTraffic {
+		return
+	}
+
+	w, ok := recordRxFiles[user]
+	if !ok {
+		var err error
+		w, err = newWavWriter(recordFileName("rx", user, channel))
+		if err != nil {
+			log.Println("error: Cannot Create Traffic Recording ", err)
+			recordTraffic = false
+			return
+		}
+		recordRxFiles[user] = w
+	}
+
+	if err := w.write(pcm); err != nil {
+		log.Println("error: Cannot Write Traffic Recording ", err)
+	}
+}
+
+// recordRxStop closes the file of a user who stopped talking
+func recordRxStop(user string) {
+	recordMutex.Lock()
+	defer recordMutex.Unlock()
+
+	if w, ok := recordRxFiles[user]; ok {
+		recordCloseLocked(w)
+		delete(recordRxFiles, user)
+	}
+}
+
+// recordTxFrame appends a captured mic frame to the file of the current transmission
+func recordTxFrame(user string, channel string, pcm []int16) {
+	recordMutex.Lock()
+	defer recordMutex.Unlock()
+
+	if !recordMic {
+		return
+	}
+
+	if recordTxFile == nil {
+		var err error
+		recordTxFile, err = newWavWriter(recordFileName("tx", user, channel))
+		if err != nil {
+			log.Println("error: Cannot Create Mic Recording ", err)
+			recordMic = false
+			return
+		}
+	}
+
+	if err := recordTxFile.write(pcm); err != nil {
+		log.Println("error: Cannot Write Mic Recording ", err)
+	}
+}
+
+// recordTxStop closes the file of the transmission that just ended
+func recordTxStop() {
+	recordMutex.Lock()
+	defer recordMutex.Unlock()
+
+	if recordTxFile != nil {
+		recordCloseLocked(recordTxFile)
+		recordTxFile = nil
+	}
+}
+
+func recordCloseLocked(w *wavWriter) {
+	if err := w.close(); err != nil {
+		log.Println("error: Cannot Close Recording ", w.file.Name(), err)
+		return
+	}
+	log.Printf("info: Recording Saved %s (%v)\n", filepath.Base(w.file.Name()), time.Since(w.started).Round(time.Millisecond))
+	go recordRotate()
+}
+
+// recordRotate zips the recordings into the archive path once more than recordmaxfiles are
+// waiting in the save path, and keeps only the newest recordarchivekeep archives
+func recordRotate() {
+	recordRotateMux.Lock()
+	defer recordRotateMux.Unlock()
+
+	if AudioRecordMaxFiles <= 0 {
+		return
+	}
+
+	recordings, err := filepath.Glob(filepath.Join(AudioRecordSavePath, "*.wav"))
+	if err != nil || len(recordings) <= AudioRecordMaxFiles {
+		return
+	}
+
+	// leave files that are still being written alone
+	recordMutex.Lock()
+	open := make(map[string]bool)
+	for _, w := range recordRxFiles {
+		open[w.file.Name()] = true
+	}
+	if recordTxFile != nil {
+		open[recordTxFile.file.Name()] = true
+	}
+	recordMutex.Unlock()
+
+	stamp := time.Now().Format("20060102-150405")
+	staging := filepath.Join(AudioRecordSavePath, "rotate-"+stamp)
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		log.Println("error: Cannot Create Recording Rotation Directory ", err)
+		return
+	}
+
+	var staged []string
+	for _, recording := range recordings {
+		if open[recording] {
+			continue
+		}
+		if err := os.Rename(recording, filepath.Join(staging, filepath.Base(recording))); err != nil {
+			log.Println("error: Cannot Move Recording for Archiving ", err)
+			continue
+		}
+		staged = append(staged, recording)
+	}
+
+	archive := filepath.Join(AudioRecordArchivePath, "recordings-"+stamp+".zip")
+
+	err = os.MkdirAll(AudioRecordArchivePath, 0755)
+	if err == nil {
+		err = zipit(staging, archive)
+		if err != nil {
+			os.Remove(archive)
+		}
+	}
+	if err != nil {
+		// the recordings are only deleted once they are safely in an archive
+		log.Println("error: Cannot Archive Recordings, Keeping Them in ", AudioRecordSavePath, " ", err)
+		unstageRecordings(staging, staged)
+		return
+	}
+
+	if err := os.RemoveAll(staging); err != nil {
+		log.Println("error: Cannot Remove Recording Rotation Directory ", err)
+	}
+	log.Println("info: Recordings Archived to ", archive)
+
+	if AudioRecordArchiveKeep <= 0 {
+		return
+	}
+
+	files, err := ioutil.ReadDir(AudioRecordArchivePath)
+	if err != nil {
+		return
+	}
+
+	var archives []string
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), "recordings-") && strings.HasSuffix(file.Name(), ".zip") {
+			archives = append(archives, file.Name())
+		}
+	}
+	sort.Strings(archives)
+
+	for len(archives) > AudioRecordArchiveKeep {
+		if err := os.Remove(filepath.Join(AudioRecordArchivePath, archives[0])); err != nil {
+			log.Println("error: Cannot Remove Old Recording Archive ", err)
+		} else {
+			log.Println("info: Removed Old Recording Archive ", archives[0])
+		}
+		archives = archives[1:]
+	}
+}
+
+// unstageRecordings moves the recordings of a failed rotation back into the save path
+func unstageRecordings(staging string, recordings []string) {
+	for _, recording := range recordings {
+		if err := os.Rename(filepath.Join(staging, filepath.Base(recording)), recording); err != nil {
+			log.Println("error: Cannot Move Recording Back From ", staging, " ", err)
+		}
+	}
+	// only removed once empty, a recording that could not be moved back stays in staging
+	os.Remove(staging)
+}
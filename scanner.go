@@ -0,0 +1,209 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * scanner.go -> talkkonnect radio style channel scanner with dwell, hang and priority channel
+ */
+
+package talkkonnect
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jdiderik/gumble/gumble"
+)
+
+// how often the scanner checks for traffic while it is stopped on a busy channel
+const scanHoldPoll = 200 * time.Millisecond
+
+var (
+	scanMutex    sync.Mutex
+	scanStop     chan bool
+	scanActivity = make(chan *gumble.Channel, 16)
+)
+
+func scanActive() bool {
+	scanMutex.Lock()
+	defer scanMutex.Unlock()
+	return scanStop != nil
+}
+
+// scanTraffic is called for every talker that starts, the scanner stops on the channel it is in
+func scanTraffic(channel *gumble.Channel) {
+	if !scanActive() {
+		return
+	}
+	select {
+	case scanActivity <- channel:
+	default:
+	}
+}
+
+// toggleScan starts the scanner, or stops it on the channel it is on if it is running
+func (b *Talkkonnect) toggleScan() {
+	scanMutex.Lock()
+	defer scanMutex.Unlock()
+
+	if scanStop != nil {
+		close(scanStop)
+		scanStop = nil
+		log.Println("info: Scan Stopped")
+		return
+	}
+
+	scanStop = make(chan bool)
+	go b.scanRoutine(scanStop)
+	log.Printf("info: Scan Started Dwell %dms Hang %ds Priority %q Every %ds\n", ScanDwellMs, ScanHangSecs, ScanPriorityChannel, ScanPriorityIntervalSecs)
+}
+
+// scanList returns the channels to scan, the scan list from the XML config or when it is empty
+// the channels channel up/down would move through
+func (b *Talkkonnect) scanList() []*gumble.Channel {
+	if len(ScanChannels) == 0 {
+		return b.channelNavList()
+	}
+
+	var channels []*gumble.Channel
+	for _, name := range ScanChannels {
+		if channel := b.findChannel(name); channel != nil {
+			channels = append(channels, channel)
+		} else {
+			log.Println("warn: Scan Channel Not Found ", name)
+		}
+	}
+	return channels
+}
+
+func (b *Talkkonnect) scanRoutine(stop chan bool) {
+	dwell := time.Duration(ScanDwellMs) * time.Millisecond
+	priorityInterval := time.Duration(ScanPriorityIntervalSecs) * time.Second
+	lastPriority := time.Now()
+	position := 0
+
+	for {
+		if !IsConnected {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Second):
+				continue
+			}
+		}
+
+		list := b.scanList()
+		if len(list) == 0 {
+			log.Println("error: Scan List is Empty, Scan Stopped")
+			scanMutex.Lock()
+			if scanStop == stop {
+				scanStop = nil
+			}
+			scanMutex.Unlock()
+			return
+		}
+
+		var channel, priority *gumble.Channel
+		if ScanPriorityChannel != "" {
+			priority = b.findChannel(ScanPriorityChannel)
+		}
+		if priority != nil && time.Since(lastPriority) >= priorityInterval {
+			channel = priority
+			lastPriority = time.Now()
+		} else {
+			position %= len(list)
+			channel = list[position]
+			position++
+		}
+
+		if channel != b.Client.Self.Channel {
+			b.Client.Self.Move(channel)
+			prevChannelID = channel.ID
+		}
+
+		// drop activity from the channel we just left
+		for len(scanActivity) > 0 {
+			<-scanActivity
+		}
+
+		timer := time.NewTimer(dwell)
+	dwelling:
+		for {
+			select {
+			case <-stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+				break dwelling
+			case active := <-scanActivity:
+				if active != channel {
+					continue
+				}
+				timer.Stop()
+				if !b.scanHold(channel, stop) {
+					return
+				}
+				if channel == priority {
+					lastPriority = time.Now()
+				}
+				break dwelling
+			}
+		}
+	}
+}
+
+// scanHold stays on a channel with traffic until nobody talked or transmitted for the hang time,
+// it returns false when the scanner was stopped meanwhile
+func (b *Talkkonnect) scanHold(channel *gumble.Channel, stop chan bool) bool {
+	log.Println("info: Scan Stopped on Busy Channel ", channelPath(channel))
+	publishEvent(EventScanHold, eventServerData{Account: b.Name, Server: b.Address, Channel: channel.Name})
+
+	hang := time.Duration(ScanHangSecs) * time.Second
+	lastActivity := time.Now()
+	ticker := time.NewTicker(scanHoldPoll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return false
+		case <-scanActivity:
+			lastActivity = time.Now()
+		case <-ticker.C:
+			talkersMutex.Lock()
+			busy := talkers > 0
+			talkersMutex.Unlock()
+
+			if busy || b.IsTransmitting {
+				lastActivity = time.Now()
+				continue
+			}
+			if time.Since(lastActivity) >= hang {
+				log.Println("info: Scan Resumed After Hang Time on ", channelPath(channel))
+				return true
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * state.go -> talkkonnect runtime state (account, channel, volume, mute) kept across restarts
+ */
+
+package talkkonnect
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// runtime state that is remembered across restarts, the xml config is never rewritten
+var (
+	OutputVolume int = 100
+	OutputMuted  bool
+	stateMutex   sync.Mutex
+)
+
+type runtimeState struct {
+	AccountIndex int       `json:"accountindex"`
+	AccountName  string    `json:"accountname"`
+	Channel      string    `json:"channel,omitempty"`
+	Volume       int       `json:"volume"`
+	Muted        bool      `json:"muted"`
+	Saved        time.Time `json:"saved"`
+}
+
+func loadState() (*runtimeState, error) {
+	data, err := ioutil.ReadFile(StateFilenameAndPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var state runtimeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// saveState writes the state to a temp file in the same directory and renames it over the old one
+// so a power cut never leaves a half written state file behind
+func (b *Talkkonnect) saveState() {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	state := runtimeState{
+		AccountIndex: AccountIndex,
+		AccountName:  b.Name,
+		Channel:      b.ChannelName,
+		Volume:       OutputVolume,
+		Muted:        OutputMuted,
+		Saved:        time.Now(),
+	}
+
+	data, err := json.MarshalIndent(state, "", "	")
+	if err != nil {
+		log.Println("error: Cannot Encode Runtime State ", err)
+		return
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(StateFilenameAndPath), filepath.Base(StateFilenameAndPath)+".tmp")
+	if err != nil {
+		log.Println("warn: Cannot Create Runtime State File ", err)
+		return
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), StateFilenameAndPath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Println("warn: Cannot Save Runtime State to ", StateFilenameAndPath, err)
+		return
+	}
+
+	log.Printf("debug: Runtime State Saved Account %s [%d] Channel %s\n", state.AccountName, state.AccountIndex, state.Channel)
+}
+
+// restoreState picks up the account, channel, volume and mute state from the last run, the account
+// is looked up by name because its index moves when accounts are added to or removed from the xml config
+func restoreState() (index int, channel string, ok bool) {
+	state, err := loadState()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println("warn: Cannot Read Runtime State From ", StateFilenameAndPath, err)
+		}
+		return 0, "", false
+	}
+
+	if state.Volume >= 0 && state.Volume <= 100 {
+		OutputVolume = state.Volume
+	}
+	OutputMuted = state.Muted
+
+	for i, account := range getAccounts() {
+		if account.Name == state.AccountName {
+			log.Printf("info: Restored Runtime State Account %s [%d] Channel %s\n", state.AccountName, i, state.Channel)
+			return i, state.Channel, true
+		}
+	}
+
+	log.Println("warn: Account ", state.AccountName, " From Runtime State No Longer in XML config")
+	return 0, "", false
+}
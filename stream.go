@@ -30,41 +30,47 @@
 package talkkonnect
 
 import (
-	"bytes"
-	"encoding/binary"
 	"errors"
 	"fmt"
-	"github.com/jdiderik/go-openal/openal"
 	"github.com/jdiderik/gumble/gumble"
 	"github.com/jdiderik/gumble/gumbleffmpeg"
 	"log"
 	"os/exec"
 	"strconv"
+	"sync"
 	"time"
 )
 
 var (
-	errState         = errors.New("gumbleopenal: invalid state")
-	lcdtext          = [4]string{"nil", "nil", "nil", ""}
-	now              = time.Now()
-	LastTime         = now.Unix()
-	debuglevel       = 2
-	emptyBufs        = openal.NewBuffers(16)
-	StreamCounter    = 0
-	TimerTalked      = time.NewTicker(time.Millisecond * 200)
-	RXLEDStatus      = false
+	errState     = errors.New("gumbleopenal: invalid state")
+	lcdtext      = [4]string{"nil", "nil", "nil", ""}
+	now          = time.Now()
+	LastTime     = now.Unix()
+	debuglevel   = 2
+	TimerTalked  = time.NewTicker(time.Millisecond * 200)
+	RXLEDStatus  = false
+	talkers      int
+	talkersMutex sync.Mutex
 )
 
+// a talker is considered stopped after this long without audio packets
+const talkerTimeout = 200 * time.Millisecond
+
 type Stream struct {
 	client *gumble.Client
 	link   gumble.Detacher
 
-	deviceSource    *openal.CaptureDevice
+	backend         AudioBackend
 	sourceFrameSize int
 	sourceStop      chan bool
 
-	deviceSink  *openal.Device
-	contextSink *openal.Context
+	// in vox mode capture never stops, frames go to outgoing while transmitting
+	voxStop       chan bool
+	voxDone       chan bool
+	outgoing      chan<- gumble.AudioBuffer
+	outgoingMutex sync.Mutex
+
+	mixer *audioMixer
 }
 
 func New(client *gumble.Client) (*Stream, error) {
@@ -72,13 +78,25 @@ func New(client *gumble.Client) (*Stream, error) {
 		client:          client,
 		sourceFrameSize: client.Config.AudioFrameSize(),
 	}
-	s.deviceSource = openal.CaptureOpenDevice("", gumble.AudioSampleRate, openal.FormatMono16, uint32(s.sourceFrameSize))
 
-	s.deviceSink = openal.OpenDevice("")
+	backend, err := newAudioBackend()
+	if err != nil {
+		return nil, err
+	}
 
-	s.contextSink = s.deviceSink.CreateContext()
+	if err := backend.OpenCapture(s.sourceFrameSize); err != nil {
+		return nil, err
+	}
 
-	s.contextSink.Activate()
+	if err := backend.OpenPlayback(); err != nil {
+		backend.CloseCapture()
+		return nil, err
+	}
+	s.backend = backend
+	log.Println("info: Audio Backend ", backend.Name())
+
+	s.mixer = newAudioMixer()
+	go s.mixer.run(backend)
 
 	s.link = client.Config.AttachAudio(s)
 
@@ -90,16 +108,16 @@ func (s *Stream) Destroy() {
 		log.Println("debug: Destroy Stream Source")
 	}
 	s.link.Detach()
-	if s.deviceSource != nil {
-		s.StopSource()
-		s.deviceSource.CaptureCloseDevice()
-		s.deviceSource = nil
+	if s.mixer != nil {
+		s.mixer.close()
+		s.mixer = nil
 	}
-	if s.deviceSink != nil {
-		s.contextSink.Destroy()
-		s.deviceSink.CloseDevice()
-		s.contextSink = nil
-		s.deviceSink = nil
+	if s.backend != nil {
+		s.StopSource()
+		s.stopVox()
+		s.backend.CloseCapture()
+		s.backend.ClosePlayback()
+		s.backend = nil
 	}
 }
 
@@ -115,8 +133,16 @@ func (s *Stream) StartSource() error {
 		s.playIntoStream(IncommingBeepSoundFilenameAndPath, IncommingBeepSoundVolume)
 	}
 
-	s.deviceSource.CaptureStart()
 	s.sourceStop = make(chan bool)
+
+	if s.voxStop != nil {
+		s.outgoingMutex.Lock()
+		s.outgoing = s.client.AudioOutgoing()
+		s.outgoingMutex.Unlock()
+		return nil
+	}
+
+	s.backend.StartCapture()
 	go s.sourceRoutine()
 	return nil
 }
@@ -130,99 +156,109 @@ func (s *Stream) StopSource() error {
 	}
 	close(s.sourceStop)
 	s.sourceStop = nil
-	s.deviceSource.CaptureStop()
-	s.deviceSource.CaptureCloseDevice()
+
+	if s.voxStop != nil {
+		s.outgoingMutex.Lock()
+		if s.outgoing != nil {
+			close(s.outgoing)
+			s.outgoing = nil
+		}
+		s.outgoingMutex.Unlock()
+	} else {
+		s.backend.StopCapture()
+	}
 
 	if RogerBeepSoundEnabled {
 		log.Println("debug: Rogerbeep Playing")
 		s.playIntoStream(RogerBeepSoundFilenameAndPath, RogerBeepSoundVolume)
 	}
 
-	s.deviceSource = openal.CaptureOpenDevice("", gumble.AudioSampleRate, openal.FormatMono16, uint32(s.sourceFrameSize))
-
 	return nil
 }
 
+// OnAudioStream runs one pipeline per speaking user, its audio is fed to the mixer so overlapping
+// talkers are all heard instead of only the first one
 func (s *Stream) OnAudioStream(e *gumble.AudioStreamEvent) {
+	mixer := s.mixer
+	if mixer == nil {
+		return
+	}
 
-	StreamCounter++
-
-	TalkedTicker := time.NewTicker(200 * time.Millisecond)
-	Talking := make(chan bool)
-
-	if StreamCounter == 1 {
-		go func() {
-			for {
-				select {
-				case <-Talking:
-					TalkedTicker.Reset(200 * time.Millisecond)
-					if RXLEDStatus == false {
-						RXLEDStatus = true
-						log.Println("info: Speaking->", *e.LastSpeaker)
-						t := time.Now()
+	go func() {
+		track := mixer.addTrack(e.User.Name)
+		defer mixer.removeTrack(track)
+
+		talking := false
+		silence := time.NewTimer(talkerTimeout)
+		silence.Stop()
+
+		for {
+			select {
+			case packet, ok := <-e.C:
+				if !ok {
+					if talking {
+						talkerStop(e.User)
 					}
-				case <-TalkedTicker.C:
-					RXLEDStatus = false
-					TalkedTicker.Stop()
-
+					return
 				}
-			}
-		}()
-	}
 
-	go func() {
-		if StreamCounter > 1 {
-			StreamCounter--
-			return
-		}
+				metricsCountAudioPacket()
 
-		source = openal.NewSource()
-		emptyBufs = openal.NewBuffers(16)
+				if !talking {
+					talking = true
+					talkerStart(e.User)
+				}
+				if !silence.Stop() {
+					select {
+					case <-silence.C:
+					default:
+					}
+				}
+				silence.Reset(talkerTimeout)
 
-		reclaim := func() {
-			if n := source.BuffersProcessed(); n > 0 {
-				reclaimedBufs := make(openal.Buffers, n)
-				source.UnqueueBuffers(reclaimedBufs)
-				emptyBufs = append(emptyBufs, reclaimedBufs...)
+				if CancellableStream && NowStreaming {
+					pstream.Stop()
+				}
+
+				recordRxPacket(e.User.Name, e.User.Channel.Name, []int16(packet.AudioBuffer))
+				mixer.push(track, packet.AudioBuffer)
+			case <-silence.C:
+				talking = false
+				talkerStop(e.User)
 			}
 		}
+	}()
+}
 
-		var raw [gumble.AudioMaximumFrameSize * 2]byte
-
-		for packet := range e.C {
-			Talking <- true
+// talkerStart and talkerStop track who is talking, the rx led stays on while anyone talks
+func talkerStart(user *gumble.User) {
+	talkersMutex.Lock()
+	talkers++
+	RXLEDStatus = talkers > 0
+	talkersMutex.Unlock()
 
-			if CancellableStream && NowStreaming {
-				pstream.Stop()
-			}
+	monitored := monitoring(user.Channel) && user.Channel != user.Client.Self.Channel
+	if monitored {
+		log.Println("info: Speaking->", user.Name, " on Monitored Channel ", channelPath(user.Channel))
+	} else {
+		log.Println("info: Speaking->", user.Name, " on Channel ", channelPath(user.Channel))
+	}
+	metricsCountTalkSpurt(user.Name)
+	scanTraffic(user.Channel)
+	callTalker(user)
+	publishEvent(EventTalkerStart, eventUserData{User: user.Name, Session: user.Session, Channel: user.Channel.Name, ChannelID: user.Channel.ID, Monitored: monitored})
+}
 
-			samples := len(packet.AudioBuffer)
+func talkerStop(user *gumble.User) {
+	talkersMutex.Lock()
+	if talkers > 0 {
+		talkers--
+	}
+	RXLEDStatus = talkers > 0
+	talkersMutex.Unlock()
 
-			if samples > cap(raw) {
-				continue
-			}
-			for i, value := range packet.AudioBuffer {
-				binary.LittleEndian.PutUint16(raw[i*2:], uint16(value))
-			}
-			reclaim()
-			if len(emptyBufs) == 0 {
-				emptyBufs = openal.NewBuffers(16)
-				continue
-			}
-			last := len(emptyBufs) - 1
-			buffer := emptyBufs[last]
-			emptyBufs = emptyBufs[:last]
-			buffer.SetData(openal.FormatMono16, raw[:samples*2], gumble.AudioSampleRate)
-			source.QueueBuffer(buffer)
-			if source.State() != openal.Playing {
-				source.Play()
-			}
-			Talking <- false
-		}
-		reclaim()
-		emptyBufs.Delete()
-		source.Delete()
-	}()
+	recordRxStop(user.Name)
+	publishEvent(EventTalkerStop, eventUserData{User: user.Name, Session: user.Session, Channel: user.Channel.Name, ChannelID: user.Channel.ID, Monitored: monitoring(user.Channel) && user.Channel != user.Client.Self.Channel})
 }
 
 func (s *Stream) sourceRoutine() {
@@ -231,9 +267,12 @@ func (s *Stream) sourceRoutine() {
 
 	if frameSize != s.sourceFrameSize {
 		log.Println("error: FrameSize Error!")
-		s.deviceSource.CaptureCloseDevice()
 		s.sourceFrameSize = frameSize
-		s.deviceSource = openal.CaptureOpenDevice("", gumble.AudioSampleRate, openal.FormatMono16, uint32(s.sourceFrameSize))
+		s.backend.CloseCapture()
+		if err := s.backend.OpenCapture(frameSize); err != nil {
+			log.Println("error: ", err)
+		}
+		s.backend.StartCapture()
 	}
 
 	ticker := time.NewTicker(interval)
@@ -252,14 +291,11 @@ func (s *Stream) sourceRoutine() {
 			return
 		case <-ticker.C:
 			//this is for encoding (transmitting)
-			buff := s.deviceSource.CaptureSamples(uint32(frameSize))
-			if len(buff) != frameSize*2 {
+			int16Buffer := s.backend.ReadFrame(frameSize)
+			if int16Buffer == nil {
 				continue
 			}
-			int16Buffer := make([]int16, frameSize)
-			for i := range int16Buffer {
-				int16Buffer[i] = int16(binary.LittleEndian.Uint16(buff[i*2 : (i+1)*2]))
-			}
+			recordTxFrame(s.client.Self.Name, s.client.Self.Channel.Name, int16Buffer)
 			outgoing <- gumble.AudioBuffer(int16Buffer)
 		}
 	}
@@ -332,6 +368,9 @@ func (b *Talkkonnect) OpenStream() {
 		FatalCleanUp("Stream Open Error " + err.Error())
 	} else {
 		b.Stream = stream
+		if VoxEnabled {
+			stream.StartVox(b.voxTransmit)
+		}
 	}
 }
 
@@ -0,0 +1,117 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * txtimeout.go -> talkkonnect tx watchdog, stops transmissions longer than txtimeoutsecs and locks tx out
+ */
+
+package talkkonnect
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// the watchdog is armed by every TransmitStart whatever started it (keys, api, mqtt or vox)
+var (
+	txWatchdogMutex  sync.Mutex
+	txWatchdog       *time.Timer
+	txWatchdogSerial uint64
+	txLockoutUntil   time.Time
+)
+
+// txLockedOut returns how much longer tx is locked out after a time out, 0 when tx is allowed
+func txLockedOut() time.Duration {
+	txWatchdogMutex.Lock()
+	defer txWatchdogMutex.Unlock()
+	if remaining := time.Until(txLockoutUntil); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+func (b *Talkkonnect) txWatchdogStart() {
+	if !TxTimeOutEnabled || TxTimeOutSecs <= 0 {
+		return
+	}
+
+	txWatchdogMutex.Lock()
+	defer txWatchdogMutex.Unlock()
+
+	if txWatchdog != nil {
+		txWatchdog.Stop()
+	}
+	txWatchdogSerial++
+	serial := txWatchdogSerial
+	txWatchdog = time.AfterFunc(time.Duration(TxTimeOutSecs)*time.Second, func() {
+		b.txTimedOut(serial)
+	})
+}
+
+func (b *Talkkonnect) txWatchdogStop() {
+	txWatchdogMutex.Lock()
+	defer txWatchdogMutex.Unlock()
+
+	if txWatchdog != nil {
+		txWatchdog.Stop()
+		txWatchdog = nil
+	}
+	txWatchdogSerial++
+}
+
+// txTimedOut runs when a transmission outlived txtimeoutsecs, serial ignores a watchdog that fired
+// while its transmission was already being stopped
+func (b *Talkkonnect) txTimedOut(serial uint64) {
+	txWatchdogMutex.Lock()
+	if serial != txWatchdogSerial {
+		txWatchdogMutex.Unlock()
+		return
+	}
+	txWatchdog = nil
+	if !b.IsTransmitting || !IsConnected {
+		txWatchdogMutex.Unlock()
+		return
+	}
+	lockout := time.Duration(TxTimeOutLockoutSecs) * time.Second
+	txLockoutUntil = time.Now().Add(lockout)
+	txWatchdogMutex.Unlock()
+
+	log.Printf("alert: TX Timed Out After %d Seconds, Transmission Stopped and Locked Out for %d Seconds\n", TxTimeOutSecs, TxTimeOutLockoutSecs)
+	b.TransmitStop(false)
+
+	metricsCountTxTimeout()
+	publishEvent(EventTxTimeout, eventTxData{Channel: b.Client.Self.Channel.Name, DurationSeconds: float64(TxTimeOutSecs), LockoutSeconds: lockout.Seconds()})
+
+	if AlertSoundEnabled {
+		// the channel hears why the transmission was cut off as well as the local speaker
+		if IsConnected && b.Stream != nil {
+			b.Stream.playIntoStream(AlertSoundFilenameAndPath, AlertSoundVolume)
+		}
+		if err := playWavLocal(AlertSoundFilenameAndPath, int(AlertSoundVolume*100)); err != nil {
+			log.Println("error: playWavLocal(AlertSoundFilenameAndPath) Returned Error: ", err)
+		}
+	}
+}
@@ -188,6 +188,25 @@ func playWavLocal(filepath string, playbackvolume int) error {
 
 	return nil
 }
+
+// announce speaks text on the local speaker with espeak when it is installed, otherwise the joined event sound is played
+func announce(text string) {
+	log.Println("info: Announcing ", text)
+
+	if isCommandAvailable("espeak") {
+		if err := exec.Command("espeak", text).Run(); err != nil {
+			log.Println("error: espeak Returned Error: ", err)
+		}
+		return
+	}
+
+	if EventSoundEnabled {
+		if err := playWavLocal(EventJoinedSoundFilenameAndPath, 100); err != nil {
+			log.Println("error: playWavLocal(EventJoinedSoundFilenameAndPath) Returned Error: ", err)
+		}
+	}
+}
+
 func clearfiles() { // Testing os.Remove to delete files
 	err := os.RemoveAll(`/avrec`)
 	if err != nil {
@@ -226,26 +245,24 @@ func fileserve4() {
 }
 
 func zipit(source, target string) error {
-	zipfile, err := os.Create(target)
+	info, err := os.Stat(source)
 	if err != nil {
 		return err
 	}
-	defer zipfile.Close()
-
-	archive := zip.NewWriter(zipfile)
-	defer archive.Close()
 
-	info, err := os.Stat(source)
+	zipfile, err := os.Create(target)
 	if err != nil {
-		return nil
+		return err
 	}
 
+	archive := zip.NewWriter(zipfile)
+
 	var baseDir string
 	if info.IsDir() {
 		baseDir = filepath.Base(source)
 	}
 
-	filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+	err = filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -283,6 +300,13 @@ func zipit(source, target string) error {
 		return err
 	})
 
+	// the central directory is only written on close, an archive that did not close cleanly is corrupt
+	if closeErr := archive.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := zipfile.Close(); err == nil {
+		err = closeErr
+	}
 	return err
 }
 
@@ -0,0 +1,241 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * vox.go -> talkkonnect voice operated transmit, keys tx from the level of the captured mic audio
+ */
+
+package talkkonnect
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"github.com/jdiderik/gumble/gumble"
+)
+
+// the pre-roll may grow to this while the transmission is being keyed (incoming beep playing)
+const voxMaxPending = time.Second
+
+// voxKeyed is true while the current transmission was started by vox, so vox only ever
+// unkeys its own transmissions and never a manual ptt
+var voxKeyed bool
+
+// voxDetector opens when the level stays above the threshold for the attack time and closes
+// when it stays below the threshold minus the hysteresis for the hang time
+type voxDetector struct {
+	openLevel    float64
+	closeLevel   float64
+	attackFrames int
+	hangFrames   int
+	above        int
+	below        int
+	open         bool
+}
+
+func newVoxDetector(interval time.Duration) *voxDetector {
+	return &voxDetector{
+		openLevel:    dbfsToLevel(VoxThresholdDB),
+		closeLevel:   dbfsToLevel(VoxThresholdDB - VoxHysteresisDB),
+		attackFrames: voxFrames(time.Duration(VoxAttackMs)*time.Millisecond, interval),
+		hangFrames:   voxFrames(time.Duration(VoxHangMs)*time.Millisecond, interval),
+	}
+}
+
+// process feeds the level of one frame and returns true when the detector opened or closed
+func (v *voxDetector) process(level float64) bool {
+	if !v.open {
+		if level < v.openLevel {
+			v.above = 0
+			return false
+		}
+		v.above++
+		if v.above < v.attackFrames {
+			return false
+		}
+		v.open = true
+		v.below = 0
+		return true
+	}
+
+	if level >= v.closeLevel {
+		v.below = 0
+		return false
+	}
+	v.below++
+	if v.below < v.hangFrames {
+		return false
+	}
+	v.open = false
+	v.above = 0
+	return true
+}
+
+func voxFrames(duration time.Duration, interval time.Duration) int {
+	frames := int((duration + interval - 1) / interval)
+	if frames < 1 {
+		frames = 1
+	}
+	return frames
+}
+
+// dbfsToLevel converts dB relative to full scale to a linear rms level between 0 and 1
+func dbfsToLevel(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+// pcmLevel returns the rms level of a frame between 0 and 1
+func pcmLevel(pcm []int16) float64 {
+	if len(pcm) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, sample := range pcm {
+		value := float64(sample) / math.MaxInt16
+		sum += value * value
+	}
+	return math.Sqrt(sum / float64(len(pcm)))
+}
+
+// StartVox captures continuously and calls transmit(true) when voice is detected and
+// transmit(false) after the hang time, transmit is always called from the same goroutine
+func (s *Stream) StartVox(transmit func(open bool)) {
+	if s.voxStop != nil {
+		return
+	}
+
+	s.voxStop = make(chan bool)
+	s.voxDone = make(chan bool)
+	events := make(chan bool, 4)
+
+	s.backend.StartCapture()
+	go s.voxRoutine(events)
+
+	go func() {
+		for {
+			select {
+			case <-s.voxDone:
+				return
+			case open := <-events:
+				transmit(open)
+			}
+		}
+	}()
+
+	log.Printf("info: VOX Enabled Threshold %.1fdBFS Hysteresis %.1fdB Attack %dms Hang %dms Pre-Roll %dms\n", VoxThresholdDB, VoxHysteresisDB, VoxAttackMs, VoxHangMs, VoxPreRollMs)
+}
+
+func (s *Stream) stopVox() {
+	if s.voxStop == nil {
+		return
+	}
+	close(s.voxStop)
+	<-s.voxDone
+	s.voxStop = nil
+}
+
+// voxRoutine reads every captured frame, keeps the last pre-roll frames while idle and sends
+// them ahead of the live audio once the transmission is keyed so the first syllable is not lost
+func (s *Stream) voxRoutine(events chan<- bool) {
+	defer close(s.voxDone)
+
+	interval := s.client.Config.AudioInterval
+	frameSize := s.client.Config.AudioFrameSize()
+	detector := newVoxDetector(interval)
+	preRollFrames := voxFrames(time.Duration(VoxPreRollMs)*time.Millisecond, interval)
+	if VoxPreRollMs <= 0 {
+		preRollFrames = 0
+	}
+	pendingFrames := voxFrames(voxMaxPending, interval) + preRollFrames
+	var preRoll []gumble.AudioBuffer
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.voxStop:
+			return
+		case <-ticker.C:
+			frame := s.backend.ReadFrame(frameSize)
+			if frame == nil {
+				continue
+			}
+
+			if detector.process(pcmLevel(frame)) {
+				if detector.open {
+					log.Println("debug: VOX Voice Detected")
+				} else {
+					log.Println("debug: VOX Hang Time Expired")
+				}
+				select {
+				case events <- detector.open:
+				default:
+				}
+			}
+
+			s.outgoingMutex.Lock()
+			if s.outgoing != nil {
+				for _, buffered := range preRoll {
+					recordTxFrame(s.client.Self.Name, s.client.Self.Channel.Name, buffered)
+					s.outgoing <- buffered
+				}
+				preRoll = preRoll[:0]
+				recordTxFrame(s.client.Self.Name, s.client.Self.Channel.Name, frame)
+				s.outgoing <- gumble.AudioBuffer(frame)
+			} else {
+				limit := preRollFrames
+				if detector.open {
+					limit = pendingFrames
+				}
+				preRoll = append(preRoll, gumble.AudioBuffer(frame))
+				if over := len(preRoll) - limit; over > 0 {
+					preRoll = append(preRoll[:0], preRoll[over:]...)
+				}
+			}
+			s.outgoingMutex.Unlock()
+		}
+	}
+}
+
+// voxTransmit keys and unkeys the transmitter for the vox detector
+func (b *Talkkonnect) voxTransmit(open bool) {
+	if open {
+		if b.IsTransmitting {
+			return
+		}
+		log.Println("info: VOX Start Transmitting")
+		b.TransmitStart()
+		voxKeyed = b.IsTransmitting
+		return
+	}
+
+	if b.IsTransmitting && voxKeyed {
+		log.Println("info: VOX Stop Transmitting")
+		b.TransmitStop(true)
+	}
+	voxKeyed = false
+}
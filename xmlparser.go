@@ -35,14 +35,13 @@ import (
 	"github.com/jdiderik/go-openal/openal"
 	"github.com/jdiderik/gumble/gumbleffmpeg"
 	"golang.org/x/sys/unix"
-	"io"
 	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -62,36 +61,50 @@ var (
 	BackLightTimePtr           = &BackLightTime
 	ConnectAttempts            = 0
 	IsConnected           bool = false
-	source                     = openal.NewSource()
 	StartTime                  = time.Now()
+	TxStartTime                = time.Now()
 	BufferToOpenALCounter      = 0
 	AccountIndex          int  = 0
+	RunningConfig         *Document
 )
 
 //account settings
 var (
-	Default     []bool
-	Name        []string
-	Server      []string
-	Username    []string
-	Password    []string
-	Insecure    []bool
-	Certificate []string
-	Channel     []string
-	Ident       []string
+	accountsMutex sync.RWMutex
+	accounts      []mumbleAccount
 )
 
+// mumbleAccount is one default account of the xml config, a reload replaces the whole list and
+// never changes it in place so the list returned by getAccounts stays consistent
+type mumbleAccount struct {
+	Name        string
+	Server      string
+	Username    string
+	Password    string
+	Insecure    bool
+	Certificate string
+	Channel     string
+	Ident       string
+}
+
+func getAccounts() []mumbleAccount {
+	accountsMutex.RLock()
+	defer accountsMutex.RUnlock()
+	return accounts
+}
+
 //software settings
 var (
-	OutputDevice       string = "Speaker"
-	OutputDeviceShort  string
-	LogFilenameAndPath string = "/var/log/talkkonnect.log"
-	Logging            string = "screen"
-	Loglevel           string = "info"
-	Daemonize          bool
-	SimplexWithMute    bool = true
-	TxCounter          bool
-	NextServerIndex    int = 0
+	OutputDevice         string = "Speaker"
+	OutputDeviceShort    string
+	LogFilenameAndPath   string = "/var/log/talkkonnect.log"
+	Logging              string = "screen"
+	Loglevel             string = "info"
+	Daemonize            bool
+	SimplexWithMute      bool = true
+	TxCounter            bool
+	NextServerIndex      int = 0
+	StateFilenameAndPath string
 )
 
 //autoprovision settings
@@ -126,6 +139,73 @@ var (
 	StreamSoundVolume                 float32
 )
 
+//smtp settings
+var (
+	EmailEnabled       bool
+	EmailServer        string = "smtp.gmail.com:587"
+	EmailUsername      string
+	EmailPassword      string
+	EmailReceiver      string
+	EmailSubject       string
+	EmailMessage       string
+	EmailGpsDateTime   bool
+	EmailGpsLatLong    bool
+	EmailGoogleMapsURL bool
+)
+
+//mqtt beacon settings
+var (
+	MQTTBeaconEnabled          bool
+	MQTTBeaconTopic            string
+	MQTTBeaconIntervalSecs     int = 300
+	MQTTBeaconSmart            bool
+	MQTTBeaconSlowSpeedKmh     float64 = 5
+	MQTTBeaconSlowIntervalSecs int     = 600
+	MQTTBeaconFastSpeedKmh     float64 = 90
+	MQTTBeaconFastIntervalSecs int     = 60
+	MQTTBeaconMinTurnAngle     float64 = 28
+	MQTTBeaconTurnSlope        float64 = 255
+	MQTTBeaconMinTurnSecs      int     = 15
+)
+
+//home assistant mqtt discovery settings
+var (
+	HomeAssistantEnabled bool
+	HomeAssistantPrefix  string = "homeassistant"
+	HomeAssistantName    string
+)
+
+//gps settings
+var (
+	GPSEnabled               bool
+	GPSPort                  string = "/dev/ttyACM0"
+	GPSBaud                  int    = 9600
+	GPSParity                string = "none"
+	GPSStopBits              int    = 1
+	GPSDataBits              int    = 8
+	GPSFile                  string
+	GPSReplayDelayMs         int    = 1000
+	GPSReportMode            string = "none"
+	GPSReportIntervalSecs    int    = 30
+	GPSReportMinMoveMeters   float64
+	GPSReportMaxIntervalSecs int
+)
+
+//panic function settings
+var (
+	PEnabled           bool
+	PFilenameAndPath   string
+	PVolume            float32 = 1
+	PSendIdent         bool
+	PMessage           string = "Panic Message Sent!"
+	PMailEnabled       bool
+	PRecursive         bool
+	PSendGpsLocation   bool
+	PTxLockEnabled     bool
+	PTxlockTimeOutSecs int
+	PRepeatSecs        int
+)
+
 //api settings
 var (
 	APIEnabled            bool
@@ -133,6 +213,7 @@ var (
 	APIDisplayMenu        bool
 	APIChannelUp          bool
 	APIChannelDown        bool
+	APIChangeChannel      bool
 	APIMute               bool
 	APICurrentVolumeLevel bool
 	APIDigitalVolumeUp    bool
@@ -153,6 +234,11 @@ var (
 	APIPingServersEnabled bool
 	APIRepeatTxLoopTest   bool
 	APIPrintXmlConfig     bool
+	APIReloadConfig       bool
+	APIMetrics            bool
+	APIPreset             bool
+	APICall               bool
+	APITextMessage        bool
 )
 
 
@@ -183,10 +269,100 @@ var (
 	TargetBoard string = "pc"
 )
 
+//reconnect settings
+var (
+	ReconnectInitialDelaySecs int     = 2
+	ReconnectMaxDelaySecs     int     = 120
+	ReconnectMultiplier       float64 = 2
+	ReconnectJitter           float64 = 0.2
+	ReconnectFailoverAfter    int
+)
+
+//health monitor settings
+var (
+	HealthEnabled          bool
+	HealthIntervalSecs     int = 30
+	HealthTimeoutSecs      int = 5
+	HealthMaxLatencyMs     int
+	HealthFailureThreshold int = 3
+	HealthPreferredAccount string
+	HealthReturnAfter      int = 3
+)
+
+//audio backend settings
+var (
+	AudioBackendName    string = "openal"
+	AudioCaptureDevice  string
+	AudioPlaybackDevice string
+	AudioCaptureFile    string
+	AudioPlaybackFile   string
+)
+
+//vox settings
+var (
+	VoxEnabled      bool
+	VoxThresholdDB  float64 = -40
+	VoxHysteresisDB float64 = 6
+	VoxAttackMs     int     = 40
+	VoxHangMs       int     = 800
+	VoxPreRollMs    int     = 300
+)
+
+//audio recording settings
+var (
+	AudioRecordEnabled     bool
+	AudioRecordOnStart     bool
+	AudioRecordMode        string = "traffic"
+	AudioRecordSavePath    string = "/avrec"
+	AudioRecordArchivePath string = "/avrec/archive"
+	AudioRecordMaxFiles    int    = 500
+	AudioRecordArchiveKeep int    = 10
+)
+
+//channel navigation settings
+var (
+	ChannelNavInclude       []string
+	ChannelNavExclude       []string
+	ChannelNavEnterableOnly bool
+	ChannelNavWrapAround    bool
+)
+
+//scanner settings
+var (
+	ScanChannels             []string
+	ScanDwellMs              int = 2000
+	ScanHangSecs             int = 5
+	ScanPriorityChannel      string
+	ScanPriorityIntervalSecs int = 10
+)
+
+//private and group call settings
+var (
+	Calls               []VoiceCall
+	CallIdleTimeoutSecs int = 30
+)
+
+//text message settings
+var (
+	MessageHistorySize int = 100
+)
+
+//monitor settings
+var (
+	MonitorEnabled  bool
+	MonitorChannels []string
+)
+
+//channel preset settings, keyed by preset number
+var (
+	Presets = make(map[int]ChannelPreset)
+)
+
 //txtimeout settings
 var (
-	TxTimeOutEnabled bool
-	TxTimeOutSecs    int
+	TxTimeOutEnabled     bool
+	TxTimeOutSecs        int
+	TxTimeOutLockoutSecs int
 )
 
 
@@ -214,20 +390,64 @@ type Document struct {
 			Ident         string `xml:"ident"`
 		} `xml:"account"`
 	} `xml:"accounts"`
+	Presets struct {
+		Preset []struct {
+			Number  int    `xml:"number,attr"`
+			Name    string `xml:"name"`
+			Account string `xml:"account"`
+			Channel string `xml:"channel"`
+		} `xml:"preset"`
+	} `xml:"presets"`
 	Global struct {
 		Software struct {
 			Settings struct {
-				OutputDevice       string `xml:"outputdevice"`
-				OutputDeviceShort  string `xml:"outputdeviceshort"`
-				LogFilenameAndPath string `xml:"logfilenameandpath"`
-				Logging            string `xml:"logging"`
-				Loglevel           string `xml:"loglevel"`
-				Daemonize          bool   `xml:"daemonize"`
-				CancellableStream  bool   `xml:"cancellablestream"`
-				SimplexWithMute    bool   `xml:"simplexwithmute"`
-				TxCounter          bool   `xml:"txcounter"`
-				NextServerIndex    int    `xml:"nextserverindex"`
+				OutputDevice         string `xml:"outputdevice"`
+				OutputDeviceShort    string `xml:"outputdeviceshort"`
+				LogFilenameAndPath   string `xml:"logfilenameandpath"`
+				Logging              string `xml:"logging"`
+				Loglevel             string `xml:"loglevel"`
+				Daemonize            bool   `xml:"daemonize"`
+				CancellableStream    bool   `xml:"cancellablestream"`
+				SimplexWithMute      bool   `xml:"simplexwithmute"`
+				TxCounter            bool   `xml:"txcounter"`
+				NextServerIndex      int    `xml:"nextserverindex"`
+				StateFilenameAndPath string `xml:"statefilenameandpath"`
 			} `xml:"settings"`
+			AutoProvisioning struct {
+				Enabled      bool   `xml:"enabled,attr"`
+				TkID         string `xml:"tkid"`
+				URL          string `xml:"url"`
+				SaveFilePath string `xml:"savefilepath"`
+				SaveFilename string `xml:"savefilename"`
+			} `xml:"autoprovisioning"`
+			Reconnect struct {
+				InitialDelaySecs int     `xml:"initialdelaysecs"`
+				MaxDelaySecs     int     `xml:"maxdelaysecs"`
+				Multiplier       float64 `xml:"multiplier"`
+				Jitter           float64 `xml:"jitter"`
+				FailoverAfter    int     `xml:"failoverafter"`
+			} `xml:"reconnect"`
+			Health struct {
+				Enabled          bool   `xml:"enabled,attr"`
+				IntervalSecs     int    `xml:"intervalsecs"`
+				TimeoutSecs      int    `xml:"timeoutsecs"`
+				MaxLatencyMs     int    `xml:"maxlatencyms"`
+				FailureThreshold int    `xml:"failurethreshold"`
+				PreferredAccount string `xml:"preferredaccount"`
+				ReturnAfter      int    `xml:"returnafter"`
+			} `xml:"health"`
+			SMTP struct {
+				Enabled       bool   `xml:"enabled,attr"`
+				Server        string `xml:"server"`
+				Username      string `xml:"username"`
+				Password      string `xml:"password"`
+				Receiver      string `xml:"receiver"`
+				Subject       string `xml:"subject"`
+				Message       string `xml:"message"`
+				GpsDateTime   bool   `xml:"gpsdatetime"`
+				GpsLatLong    bool   `xml:"gpslatlong"`
+				GoogleMapsURL bool   `xml:"googlemapurl"`
+			} `xml:"smtp"`
 			Sounds struct {
 				Event struct {
 					Enabled                bool   `xml:"enabled,attr"`
@@ -261,9 +481,71 @@ type Document struct {
 					Volume          float32 `xml:"volume"`
 				} `xml:"stream"`
 			} `xml:"sounds"`
+			Audio struct {
+				Backend        string `xml:"backend"`
+				CaptureDevice  string `xml:"capturedevice"`
+				PlaybackDevice string `xml:"playbackdevice"`
+				CaptureFile    string `xml:"capturefile"`
+				PlaybackFile   string `xml:"playbackfile"`
+				Mixer          struct {
+					User []struct {
+						Name string   `xml:"name,attr"`
+						Gain *float32 `xml:"gain,attr"`
+					} `xml:"user"`
+				} `xml:"mixer"`
+			} `xml:"audio"`
+			ChannelNavigation struct {
+				Include       []string `xml:"include"`
+				Exclude       []string `xml:"exclude"`
+				EnterableOnly bool     `xml:"enterableonly"`
+				WrapAround    bool     `xml:"wraparound"`
+			} `xml:"channelnavigation"`
+			Scan struct {
+				Channel              []string `xml:"channel"`
+				DwellMs              int      `xml:"dwellms"`
+				HangSecs             int      `xml:"hangsecs"`
+				PriorityChannel      string   `xml:"prioritychannel"`
+				PriorityIntervalSecs int      `xml:"priorityintervalsecs"`
+			} `xml:"scan"`
+			Calls struct {
+				IdleTimeoutSecs *int `xml:"idletimeoutsecs"`
+				Call            []struct {
+					Name    string   `xml:"name,attr"`
+					User    []string `xml:"user"`
+					Channel []struct {
+						Name        string `xml:",chardata"`
+						SubChannels bool   `xml:"subchannels,attr"`
+					} `xml:"channel"`
+				} `xml:"call"`
+			} `xml:"calls"`
+			TextMessages struct {
+				HistorySize *int `xml:"historysize"`
+			} `xml:"textmessages"`
+			Monitor struct {
+				Enabled bool     `xml:"enabled,attr"`
+				Channel []string `xml:"channel"`
+			} `xml:"monitor"`
+			Vox struct {
+				Enabled      bool    `xml:"enabled,attr"`
+				ThresholdDB  float64 `xml:"thresholddb"`
+				HysteresisDB float64 `xml:"hysteresisdb"`
+				AttackMs     int     `xml:"attackms"`
+				HangMs       int     `xml:"hangms"`
+				PreRollMs    int     `xml:"prerollms"`
+			} `xml:"vox"`
+			AudioRecordFunction struct {
+				Enabled           bool   `xml:"enabled,attr"`
+				RecordOnStart     bool   `xml:"recordonstart"`
+				RecordMode        string `xml:"recordmode"`
+				RecordSavePath    string `xml:"recordsavepath"`
+				RecordArchivePath string `xml:"recordarchivepath"`
+				RecordMaxFiles    int    `xml:"recordmaxfiles"`
+				RecordArchiveKeep int    `xml:"recordarchivekeep"`
+			} `xml:"audiorecordfunction"`
 			TxTimeOut struct {
 				Enabled       bool `xml:"enabled,attr"`
 				TxTimeOutSecs int  `xml:"txtimeoutsecs"`
+				LockoutSecs   int  `xml:"lockoutsecs"`
 			} `xml:"txtimeout"`
 			API struct {
 				Enabled            bool   `xml:"enabled,attr"`
@@ -271,6 +553,7 @@ type Document struct {
 				DisplayMenu        bool   `xml:"displaymenu"`
 				ChannelUp          bool   `xml:"channelup"`
 				ChannelDown        bool   `xml:"channeldown"`
+				ChangeChannel      bool   `xml:"changechannel"`
 				Mute               bool   `xml:"mute"`
 				CurrentVolumeLevel bool   `xml:"currentvolumelevel"`
 				DigitalVolumeUp    bool   `xml:"digitalvolumeup"`
@@ -291,6 +574,11 @@ type Document struct {
 				PrintXmlConfig     bool   `xml:"printxmlconfig"`
 				SendEmail          bool   `xml:"sendemail"`
 				PingServers        bool   `xml:"pingservers"`
+				ReloadConfig       bool   `xml:"reloadconfig"`
+				Metrics            bool   `xml:"metrics"`
+				Preset             bool   `xml:"preset"`
+				Call               bool   `xml:"call"`
+				TextMessage        bool   `xml:"textmessage"`
 			} `xml:"api"`
 			MQTT struct {
 				MQTTEnabled   bool   `xml:"enabled,attr"`
@@ -306,46 +594,220 @@ type Document struct {
 				MQTTAction    string `xml:"action"`
 				MQTTStore     string `xml:"store"`
 			} `xml:"mqtt"`
+			MQTTBeacon struct {
+				Enabled        bool   `xml:"enabled,attr"`
+				Topic          string `xml:"topic"`
+				IntervalSecs   int    `xml:"intervalsecs"`
+				SmartBeaconing struct {
+					Enabled          bool    `xml:"enabled,attr"`
+					SlowSpeedKmh     float64 `xml:"slowspeedkmh"`
+					SlowIntervalSecs int     `xml:"slowintervalsecs"`
+					FastSpeedKmh     float64 `xml:"fastspeedkmh"`
+					FastIntervalSecs int     `xml:"fastintervalsecs"`
+					MinTurnAngle     float64 `xml:"minturnangle"`
+					TurnSlope        float64 `xml:"turnslope"`
+					MinTurnSecs      int     `xml:"minturnsecs"`
+				} `xml:"smartbeaconing"`
+			} `xml:"mqttbeacon"`
+			HomeAssistant struct {
+				Enabled         bool   `xml:"enabled,attr"`
+				DiscoveryPrefix string `xml:"discoveryprefix"`
+				DeviceName      string `xml:"devicename"`
+			} `xml:"homeassistant"`
 		} `xml:"software"`
 		Hardware struct {
 			TargetBoard string `xml:"targetboard,attr"`
+			GPS         struct {
+				Enabled               bool    `xml:"enabled,attr"`
+				Port                  string  `xml:"port"`
+				Baud                  int     `xml:"baud"`
+				Parity                string  `xml:"parity"`
+				StopBits              int     `xml:"stopbits"`
+				DataBits              int     `xml:"databits"`
+				File                  string  `xml:"file"`
+				ReplayDelayMs         int     `xml:"replaydelayms"`
+				ReportMode            string  `xml:"reportmode"`
+				ReportIntervalSecs    int     `xml:"reportintervalsecs"`
+				ReportMinMoveMeters   float64 `xml:"reportminmovemeters"`
+				ReportMaxIntervalSecs int     `xml:"reportmaxintervalsecs"`
+			} `xml:"gps"`
+			PanicFunction struct {
+				Enabled          bool    `xml:"enabled,attr"`
+				FilenameAndPath  string  `xml:"filenameandpath"`
+				Volume           float32 `xml:"volume"`
+				SendIdent        bool    `xml:"sendident"`
+				Message          string  `xml:"panicmessage"`
+				PMailEnabled     bool    `xml:"panicemail"`
+				RecursiveMessage bool    `xml:"recursivemessage"`
+				SendGpsLocation  bool    `xml:"sendgpslocation"`
+				RepeatSecs       int     `xml:"repeatsecs"`
+				TxLock           struct {
+					Enabled     bool `xml:"enabled,attr"`
+					TimeOutSecs int  `xml:"txlocktimeoutsecs"`
+				} `xml:"txlock"`
+			} `xml:"panicfunction"`
 		} `xml:"hardware"`
 	} `xml:"global"`
 }
 
 func readxmlconfig(file string) error {
+	document, err := loadxmlconfig(file)
+	if err != nil {
+		return err
+	}
+
+	if countDefaultAccounts(document) == 0 {
+		FatalCleanUp("No Default Accounts Found in talkkonnect.xml File! Please Add At Least 1 Default Account in XML")
+	}
+
+	applyxmlconfig(file, document)
+	return nil
+}
+
+// loadxmlconfig parses the xml file into a fresh document without touching any of the running globals
+func loadxmlconfig(file string) (*Document, error) {
 	xmlFile, err := os.Open(file)
 	if err != nil {
-		return fmt.Errorf(err.Error())
+		return nil, fmt.Errorf(err.Error())
 	}
 	log.Println("info: Successfully Read file " + filepath.Base(file))
 	defer xmlFile.Close()
 
 	byteValue, _ := ioutil.ReadAll(xmlFile)
 
-	var document Document
+	document := &Document{}
 
-	err = xml.Unmarshal(byteValue, &document)
+	err = xml.Unmarshal(byteValue, document)
 	if err != nil {
-		return fmt.Errorf(filepath.Base(file) + " " + err.Error())
+		return nil, fmt.Errorf(filepath.Base(file) + " " + err.Error())
 	}
 
+	return document, nil
+}
+
+func countDefaultAccounts(document *Document) int {
+	count := 0
 	for i := 0; i < len(document.Accounts.Account); i++ {
 		if document.Accounts.Account[i].Default == true {
-			Name = append(Name, document.Accounts.Account[i].Name)
-			Server = append(Server, document.Accounts.Account[i].ServerAndPort)
-			Username = append(Username, document.Accounts.Account[i].UserName)
-			Password = append(Password, document.Accounts.Account[i].Password)
-			Insecure = append(Insecure, document.Accounts.Account[i].Insecure)
-			Certificate = append(Certificate, document.Accounts.Account[i].Certificate)
-			Channel = append(Channel, document.Accounts.Account[i].Channel)
-			Ident = append(Ident, document.Accounts.Account[i].Ident)
-			AccountCount++
+			count++
 		}
 	}
+	return count
+}
 
-	if AccountCount == 0 {
-		FatalCleanUp("No Default Accounts Found in talkkonnect.xml File! Please Add At Least 1 Default Account in XML")
+// applyxmlconfig populates the global variables from a parsed document, the account list is rebuilt
+// from scratch so that applying a document a second time (autoprovisioning or reload) does not duplicate
+// accounts, and published in one step so readers never see a half built list
+func applyxmlconfig(file string, document *Document) {
+	RunningConfig = document
+
+	var defaults []mumbleAccount
+	for i := 0; i < len(document.Accounts.Account); i++ {
+		if document.Accounts.Account[i].Default == true {
+			defaults = append(defaults, mumbleAccount{
+				Name:        document.Accounts.Account[i].Name,
+				Server:      document.Accounts.Account[i].ServerAndPort,
+				Username:    document.Accounts.Account[i].UserName,
+				Password:    document.Accounts.Account[i].Password,
+				Insecure:    document.Accounts.Account[i].Insecure,
+				Certificate: document.Accounts.Account[i].Certificate,
+				Channel:     document.Accounts.Account[i].Channel,
+				Ident:       document.Accounts.Account[i].Ident,
+			})
+		}
+	}
+
+	accountsMutex.Lock()
+	accounts = defaults
+	AccountCount = len(defaults)
+	accountsMutex.Unlock()
+
+	ChannelNavInclude, ChannelNavExclude = nil, nil
+	for _, pattern := range document.Global.Software.ChannelNavigation.Include {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			ChannelNavInclude = append(ChannelNavInclude, pattern)
+		}
+	}
+	for _, pattern := range document.Global.Software.ChannelNavigation.Exclude {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			ChannelNavExclude = append(ChannelNavExclude, pattern)
+		}
+	}
+	ChannelNavEnterableOnly = document.Global.Software.ChannelNavigation.EnterableOnly
+	ChannelNavWrapAround = document.Global.Software.ChannelNavigation.WrapAround
+
+	ScanChannels = nil
+	for _, channel := range document.Global.Software.Scan.Channel {
+		if channel = strings.TrimSpace(channel); channel != "" {
+			ScanChannels = append(ScanChannels, channel)
+		}
+	}
+	ScanDwellMs = document.Global.Software.Scan.DwellMs
+	ScanHangSecs = document.Global.Software.Scan.HangSecs
+	ScanPriorityChannel = strings.TrimSpace(document.Global.Software.Scan.PriorityChannel)
+	ScanPriorityIntervalSecs = document.Global.Software.Scan.PriorityIntervalSecs
+
+	if ScanDwellMs <= 0 {
+		ScanDwellMs = 2000
+	}
+
+	if ScanHangSecs <= 0 {
+		ScanHangSecs = 5
+	}
+
+	if ScanPriorityIntervalSecs <= 0 {
+		ScanPriorityIntervalSecs = 10
+	}
+
+	Calls = nil
+	for _, call := range document.Global.Software.Calls.Call {
+		voiceCall := VoiceCall{Name: strings.TrimSpace(call.Name)}
+		for _, user := range call.User {
+			if user = strings.TrimSpace(user); user != "" {
+				voiceCall.Users = append(voiceCall.Users, user)
+			}
+		}
+		for _, channel := range call.Channel {
+			if name := strings.TrimSpace(channel.Name); name != "" {
+				voiceCall.Channels = append(voiceCall.Channels, VoiceCallChannel{Name: name, SubChannels: channel.SubChannels})
+			}
+		}
+		if voiceCall.Name == "" || (len(voiceCall.Users) == 0 && len(voiceCall.Channels) == 0) {
+			continue
+		}
+		Calls = append(Calls, voiceCall)
+	}
+
+	// 0 keeps a call open until it is ended
+	CallIdleTimeoutSecs = 30
+	if document.Global.Software.Calls.IdleTimeoutSecs != nil {
+		CallIdleTimeoutSecs = *document.Global.Software.Calls.IdleTimeoutSecs
+	}
+
+	// 0 keeps no history
+	MessageHistorySize = 100
+	if document.Global.Software.TextMessages.HistorySize != nil {
+		MessageHistorySize = *document.Global.Software.TextMessages.HistorySize
+	}
+
+	MonitorEnabled = document.Global.Software.Monitor.Enabled
+	MonitorChannels = nil
+	for _, channel := range document.Global.Software.Monitor.Channel {
+		if channel = strings.TrimSpace(channel); channel != "" {
+			MonitorChannels = append(MonitorChannels, channel)
+		}
+	}
+
+	Presets = make(map[int]ChannelPreset)
+	for _, preset := range document.Presets.Preset {
+		if preset.Number < 1 || preset.Number > 99 {
+			log.Printf("warn: Preset Number %d Ignored, Presets are Numbered 1 to 99\n", preset.Number)
+			continue
+		}
+		if preset.Name == "" {
+			preset.Name = preset.Channel
+		}
+		Presets[preset.Number] = ChannelPreset{Number: preset.Number, Name: preset.Name, Account: preset.Account, Channel: preset.Channel}
 	}
 
 	exec, err := os.Executable()
@@ -423,6 +885,9 @@ func readxmlconfig(file string) error {
 	if document.Global.Software.Settings.Loglevel == "trace" || document.Global.Software.Settings.Loglevel == "debug" || document.Global.Software.Settings.Loglevel == "info" || document.Global.Software.Settings.Loglevel == "warning" || document.Global.Software.Settings.Loglevel == "error" || document.Global.Software.Settings.Loglevel == "alert" {
 		Loglevel = document.Global.Software.Settings.Loglevel
 	}
+	if Loglevel != document.Global.Software.Settings.Loglevel && document.Global.Software.Settings.Loglevel != "" {
+		log.Printf("warn: Unknown loglevel %q in XML config Ignored, Using %s\n", document.Global.Software.Settings.Loglevel, Loglevel)
+	}
 
 	if strings.ToLower(Logging) != "screen" && LogFilenameAndPath == "" {
 		LogFilenameAndPath = defaultLogPath
@@ -433,6 +898,11 @@ func readxmlconfig(file string) error {
 	SimplexWithMute = document.Global.Software.Settings.SimplexWithMute
 	TxCounter = document.Global.Software.Settings.TxCounter
 	NextServerIndex = document.Global.Software.Settings.NextServerIndex
+	StateFilenameAndPath = document.Global.Software.Settings.StateFilenameAndPath
+
+	if StateFilenameAndPath == "" {
+		StateFilenameAndPath = defaultConfPath + "/" + filepath.Base(exec) + ".state"
+	}
 
 	APEnabled = document.Global.Software.AutoProvisioning.Enabled
 	TkID = document.Global.Software.AutoProvisioning.TkID
@@ -448,6 +918,52 @@ func readxmlconfig(file string) error {
 		SaveFilename = filepath.Base(exec) + ".xml" //Should default to talkkonnect.xml
 	}
 
+	ReconnectInitialDelaySecs = document.Global.Software.Reconnect.InitialDelaySecs
+	ReconnectMaxDelaySecs = document.Global.Software.Reconnect.MaxDelaySecs
+	ReconnectMultiplier = document.Global.Software.Reconnect.Multiplier
+	ReconnectJitter = document.Global.Software.Reconnect.Jitter
+	ReconnectFailoverAfter = document.Global.Software.Reconnect.FailoverAfter
+
+	if ReconnectInitialDelaySecs <= 0 {
+		ReconnectInitialDelaySecs = 2
+	}
+
+	if ReconnectMaxDelaySecs < ReconnectInitialDelaySecs {
+		ReconnectMaxDelaySecs = 120
+	}
+
+	if ReconnectMultiplier < 1 {
+		ReconnectMultiplier = 2
+	}
+
+	if ReconnectJitter < 0 || ReconnectJitter > 1 {
+		ReconnectJitter = 0.2
+	}
+
+	HealthEnabled = document.Global.Software.Health.Enabled
+	HealthIntervalSecs = document.Global.Software.Health.IntervalSecs
+	HealthTimeoutSecs = document.Global.Software.Health.TimeoutSecs
+	HealthMaxLatencyMs = document.Global.Software.Health.MaxLatencyMs
+	HealthFailureThreshold = document.Global.Software.Health.FailureThreshold
+	HealthPreferredAccount = document.Global.Software.Health.PreferredAccount
+	HealthReturnAfter = document.Global.Software.Health.ReturnAfter
+
+	if HealthIntervalSecs <= 0 {
+		HealthIntervalSecs = 30
+	}
+
+	if HealthTimeoutSecs <= 0 {
+		HealthTimeoutSecs = 5
+	}
+
+	if HealthFailureThreshold <= 0 {
+		HealthFailureThreshold = 3
+	}
+
+	if HealthReturnAfter <= 0 {
+		HealthReturnAfter = 3
+	}
+
 	EventSoundEnabled = document.Global.Software.Sounds.Event.Enabled
 	EventJoinedSoundFilenameAndPath = document.Global.Software.Sounds.Event.JoinedFilenameAndPath
 	EventLeftSoundFilenameAndPath = document.Global.Software.Sounds.Event.LeftFilenameAndPath
@@ -523,14 +1039,214 @@ func readxmlconfig(file string) error {
 
 	StreamSoundVolume = document.Global.Software.Sounds.Stream.Volume
 
+	EmailEnabled = document.Global.Software.SMTP.Enabled
+	EmailServer = strings.TrimSpace(document.Global.Software.SMTP.Server)
+	EmailUsername = document.Global.Software.SMTP.Username
+	EmailPassword = document.Global.Software.SMTP.Password
+	EmailReceiver = document.Global.Software.SMTP.Receiver
+	EmailSubject = document.Global.Software.SMTP.Subject
+	EmailMessage = document.Global.Software.SMTP.Message
+	EmailGpsDateTime = document.Global.Software.SMTP.GpsDateTime
+	EmailGpsLatLong = document.Global.Software.SMTP.GpsLatLong
+	EmailGoogleMapsURL = document.Global.Software.SMTP.GoogleMapsURL
+
+	if EmailServer == "" {
+		EmailServer = "smtp.gmail.com:587"
+	}
+
+	MQTTBeaconEnabled = document.Global.Software.MQTTBeacon.Enabled
+	MQTTBeaconTopic = strings.TrimSpace(document.Global.Software.MQTTBeacon.Topic)
+	MQTTBeaconIntervalSecs = document.Global.Software.MQTTBeacon.IntervalSecs
+	MQTTBeaconSmart = document.Global.Software.MQTTBeacon.SmartBeaconing.Enabled
+	MQTTBeaconSlowSpeedKmh = document.Global.Software.MQTTBeacon.SmartBeaconing.SlowSpeedKmh
+	MQTTBeaconSlowIntervalSecs = document.Global.Software.MQTTBeacon.SmartBeaconing.SlowIntervalSecs
+	MQTTBeaconFastSpeedKmh = document.Global.Software.MQTTBeacon.SmartBeaconing.FastSpeedKmh
+	MQTTBeaconFastIntervalSecs = document.Global.Software.MQTTBeacon.SmartBeaconing.FastIntervalSecs
+	MQTTBeaconMinTurnAngle = document.Global.Software.MQTTBeacon.SmartBeaconing.MinTurnAngle
+	MQTTBeaconTurnSlope = document.Global.Software.MQTTBeacon.SmartBeaconing.TurnSlope
+	MQTTBeaconMinTurnSecs = document.Global.Software.MQTTBeacon.SmartBeaconing.MinTurnSecs
+
+	if MQTTBeaconIntervalSecs <= 0 {
+		MQTTBeaconIntervalSecs = 300
+	}
+
+	if MQTTBeaconSlowSpeedKmh <= 0 {
+		MQTTBeaconSlowSpeedKmh = 5
+	}
+
+	if MQTTBeaconSlowIntervalSecs <= 0 {
+		MQTTBeaconSlowIntervalSecs = 600
+	}
+
+	if MQTTBeaconFastSpeedKmh <= MQTTBeaconSlowSpeedKmh {
+		MQTTBeaconFastSpeedKmh = 90
+	}
+
+	if MQTTBeaconFastIntervalSecs <= 0 {
+		MQTTBeaconFastIntervalSecs = 60
+	}
+
+	if MQTTBeaconMinTurnAngle <= 0 {
+		MQTTBeaconMinTurnAngle = 28
+	}
+
+	if MQTTBeaconTurnSlope < 0 {
+		MQTTBeaconTurnSlope = 255
+	}
+
+	if MQTTBeaconMinTurnSecs <= 0 {
+		MQTTBeaconMinTurnSecs = 15
+	}
+
+	HomeAssistantEnabled = document.Global.Software.HomeAssistant.Enabled
+	HomeAssistantPrefix = strings.Trim(strings.TrimSpace(document.Global.Software.HomeAssistant.DiscoveryPrefix), "/")
+	HomeAssistantName = strings.TrimSpace(document.Global.Software.HomeAssistant.DeviceName)
+
+	if HomeAssistantPrefix == "" {
+		HomeAssistantPrefix = "homeassistant"
+	}
+
+	GPSEnabled = document.Global.Hardware.GPS.Enabled
+	GPSPort = strings.TrimSpace(document.Global.Hardware.GPS.Port)
+	GPSBaud = document.Global.Hardware.GPS.Baud
+	GPSParity = strings.ToLower(strings.TrimSpace(document.Global.Hardware.GPS.Parity))
+	GPSStopBits = document.Global.Hardware.GPS.StopBits
+	GPSDataBits = document.Global.Hardware.GPS.DataBits
+	GPSFile = strings.TrimSpace(document.Global.Hardware.GPS.File)
+	GPSReplayDelayMs = document.Global.Hardware.GPS.ReplayDelayMs
+	GPSReportMode = strings.ToLower(strings.TrimSpace(document.Global.Hardware.GPS.ReportMode))
+	GPSReportIntervalSecs = document.Global.Hardware.GPS.ReportIntervalSecs
+	GPSReportMinMoveMeters = document.Global.Hardware.GPS.ReportMinMoveMeters
+	GPSReportMaxIntervalSecs = document.Global.Hardware.GPS.ReportMaxIntervalSecs
+
+	if GPSPort == "" {
+		GPSPort = "/dev/ttyACM0"
+	}
+
+	if GPSBaud <= 0 {
+		GPSBaud = 9600
+	}
+
+	if GPSParity == "" {
+		GPSParity = "none"
+	}
+
+	if GPSStopBits <= 0 {
+		GPSStopBits = 1
+	}
+
+	if GPSDataBits <= 0 {
+		GPSDataBits = 8
+	}
+
+	if GPSReplayDelayMs <= 0 {
+		GPSReplayDelayMs = 1000
+	}
+
+	if GPSReportMode == "" {
+		GPSReportMode = "none"
+	}
+
+	if GPSReportIntervalSecs <= 0 {
+		GPSReportIntervalSecs = 30
+	}
+
+	PEnabled = document.Global.Hardware.PanicFunction.Enabled
+	PFilenameAndPath = document.Global.Hardware.PanicFunction.FilenameAndPath
+	PVolume = document.Global.Hardware.PanicFunction.Volume
+	PSendIdent = document.Global.Hardware.PanicFunction.SendIdent
+	PMessage = document.Global.Hardware.PanicFunction.Message
+	PMailEnabled = document.Global.Hardware.PanicFunction.PMailEnabled
+	PRecursive = document.Global.Hardware.PanicFunction.RecursiveMessage
+	PSendGpsLocation = document.Global.Hardware.PanicFunction.SendGpsLocation
+	PTxLockEnabled = document.Global.Hardware.PanicFunction.TxLock.Enabled
+	PTxlockTimeOutSecs = document.Global.Hardware.PanicFunction.TxLock.TimeOutSecs
+	PRepeatSecs = document.Global.Hardware.PanicFunction.RepeatSecs
+
+	// the panic tone is the alert sound unless another one is given
+	if PFilenameAndPath == "" {
+		PFilenameAndPath = AlertSoundFilenameAndPath
+	}
+
+	if PVolume <= 0 {
+		PVolume = 1
+	}
+
+	if PMessage == "" {
+		PMessage = "Panic Message Sent!"
+	}
+
+	AudioBackendName = strings.ToLower(strings.TrimSpace(document.Global.Software.Audio.Backend))
+	AudioCaptureDevice = document.Global.Software.Audio.CaptureDevice
+	AudioPlaybackDevice = document.Global.Software.Audio.PlaybackDevice
+	AudioCaptureFile = document.Global.Software.Audio.CaptureFile
+	AudioPlaybackFile = document.Global.Software.Audio.PlaybackFile
+
+	if AudioBackendName == "" {
+		AudioBackendName = "openal"
+	}
+
+	gains := make(map[string]float32)
+	for _, user := range document.Global.Software.Audio.Mixer.User {
+		if name := strings.TrimSpace(user.Name); name != "" && user.Gain != nil && *user.Gain != 1 {
+			gains[name] = *user.Gain
+		}
+	}
+	setUserGains(gains)
+
+	VoxEnabled = document.Global.Software.Vox.Enabled
+	VoxThresholdDB = document.Global.Software.Vox.ThresholdDB
+	VoxHysteresisDB = document.Global.Software.Vox.HysteresisDB
+	VoxAttackMs = document.Global.Software.Vox.AttackMs
+	VoxHangMs = document.Global.Software.Vox.HangMs
+	VoxPreRollMs = document.Global.Software.Vox.PreRollMs
+
+	if VoxThresholdDB >= 0 {
+		VoxThresholdDB = -40
+	}
+
+	if VoxHysteresisDB < 0 {
+		VoxHysteresisDB = 6
+	}
+
+	if VoxHangMs <= 0 {
+		VoxHangMs = 800
+	}
+
+	AudioRecordEnabled = document.Global.Software.AudioRecordFunction.Enabled
+	AudioRecordOnStart = document.Global.Software.AudioRecordFunction.RecordOnStart
+	AudioRecordMode = document.Global.Software.AudioRecordFunction.RecordMode
+	AudioRecordSavePath = document.Global.Software.AudioRecordFunction.RecordSavePath
+	AudioRecordArchivePath = document.Global.Software.AudioRecordFunction.RecordArchivePath
+	AudioRecordMaxFiles = document.Global.Software.AudioRecordFunction.RecordMaxFiles
+	AudioRecordArchiveKeep = document.Global.Software.AudioRecordFunction.RecordArchiveKeep
+
+	if AudioRecordMode != "traffic" && AudioRecordMode != "mic" && AudioRecordMode != "combo" {
+		AudioRecordMode = "traffic"
+	}
+
+	if AudioRecordSavePath == "" {
+		AudioRecordSavePath = "/avrec"
+	}
+
+	if AudioRecordArchivePath == "" {
+		AudioRecordArchivePath = AudioRecordSavePath + "/archive"
+	}
+
 	TxTimeOutEnabled = document.Global.Software.TxTimeOut.Enabled
 	TxTimeOutSecs = document.Global.Software.TxTimeOut.TxTimeOutSecs
+	TxTimeOutLockoutSecs = document.Global.Software.TxTimeOut.LockoutSecs
+
+	if TxTimeOutLockoutSecs < 0 {
+		TxTimeOutLockoutSecs = 0
+	}
 
 	APIEnabled = document.Global.Software.API.Enabled
 	APIListenPort = document.Global.Software.API.ListenPort
 	APIDisplayMenu = document.Global.Software.API.DisplayMenu
 	APIChannelUp = document.Global.Software.API.ChannelUp
 	APIChannelDown = document.Global.Software.API.ChannelDown
+	APIChangeChannel = document.Global.Software.API.ChangeChannel
 	APIMute = document.Global.Software.API.Mute
 	APICurrentVolumeLevel = document.Global.Software.API.CurrentVolumeLevel
 	APIDigitalVolumeUp = document.Global.Software.API.DigitalVolumeUp
@@ -550,6 +1266,12 @@ func readxmlconfig(file string) error {
 	APIPingServersEnabled = document.Global.Software.API.Enabled
 	APIRepeatTxLoopTest = document.Global.Software.API.RepeatTxLoopTest
 	APIPrintXmlConfig = document.Global.Software.API.PrintXmlConfig
+	APIReloadConfig = document.Global.Software.API.ReloadConfig
+	APIMetrics = document.Global.Software.API.Metrics
+	APIPreset = document.Global.Software.API.Preset
+	APICall = document.Global.Software.API.Call
+	APITextMessage = document.Global.Software.API.TextMessage
+	APIScanChannels = document.Global.Software.API.ScanChannels
 
 	MQTTEnabled = document.Global.Software.MQTT.MQTTEnabled
 	MQTTTopic = document.Global.Software.MQTT.MQTTTopic
@@ -573,72 +1295,4 @@ func readxmlconfig(file string) error {
 			log.Printf("info: Successfully Added Account %s to Index [%d]\n", document.Accounts.Account[i].Name, i)
 		}
 	}
-
-	return nil
-}
-
-func modifyXMLTagServerHopping(inputXMLFile string, outputXMLFile string, nextserverindex int) {
-	xmlfilein, err := os.Open(inputXMLFile)
-	xmlfileout, err := os.Create(outputXMLFile)
-
-	if err != nil {
-		FatalCleanUp(err.Error())
-	}
-
-	defer xmlfilein.Close()
-	defer xmlfileout.Close()
-	decoder := xml.NewDecoder(xmlfilein)
-	encoder := xml.NewEncoder(xmlfileout)
-	encoder.Indent("", "	")
-
-	for {
-		token, err := decoder.Token()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Printf("error: Getting token: %v\n", err)
-			break
-		}
-
-		switch v := token.(type) {
-		case xml.StartElement:
-			if v.Name.Local == "document" {
-				var document Document
-				if v.Name.Local != "talkkonnect/xml" {
-					err = decoder.DecodeElement(&document, &v)
-					if err != nil {
-						FatalCleanUp("Cannot Find XML Tag Document" + err.Error())
-					}
-				}
-				// XML Tag to Replace
-				document.Global.Software.Settings.NextServerIndex = nextserverindex
-
-				err = encoder.EncodeElement(document, v)
-				if err != nil {
-					FatalCleanUp(err.Error())
-				}
-				continue
-			}
-
-		}
-
-		if err := encoder.EncodeToken(xml.CopyToken(token)); err != nil {
-			FatalCleanUp(err.Error())
-		}
-	}
-
-	if err := encoder.Flush(); err != nil {
-		FatalCleanUp(err.Error())
-	} else {
-		time.Sleep(2 * time.Second)
-		copyFile(inputXMLFile, inputXMLFile+".bak")
-		deleteFile(inputXMLFile)
-		copyFile(outputXMLFile, inputXMLFile)
-		c := exec.Command("reset")
-		c.Stdout = os.Stdout
-		c.Run()
-		os.Exit(0)
-	}
-
 }
@@ -0,0 +1,697 @@
+/*
+ * talkkonnect headless mumble client/gateway with lcd screen and channel control
+ * Copyright (C) 2018-2019, Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ * Software distributed under the License is distributed on an "AS IS" basis,
+ * WITHOUT WARRANTY OF ANY KIND, either express or implied. See the License
+ * for the specific language governing rights and limitations under the
+ * License.
+ *
+ * talkkonnect is the based on talkiepi and barnard by Daniel Chote and Tim Cooper
+ *
+ * The Initial Developer of the Original Code is
+ * Suvir Kumar <suvir@talkkonnect.com>
+ * Portions created by the Initial Developer are Copyright (C) Suvir Kumar. All Rights Reserved.
+ *
+ * Contributor(s):
+ *
+ * Suvir Kumar <suvir@talkkonnect.com>
+ *
+ * My Blog is at www.talkkonnect.com
+ * The source code is hosted at github.com/talkkonnect
+ *
+ * xmlvalidate.go -> talkkonnect validation of talkkonnect.xml with the xml path of every problem found
+ */
+
+package talkkonnect
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// xmlNode is one element or attribute of the document as written in the file
+type xmlNode struct {
+	path string // path with element indexes, used in messages e.g. accounts/account[1]/serverandport
+	key  string // path without list indexes, used to look up the expected type
+	text string
+}
+
+// ValidateConfig checks the configuration file and returns every problem found, each prefixed with the
+// xml path of the offending element. An empty result means the file is valid.
+func ValidateConfig(file string) []string {
+	var problems []string
+
+	byteValue, err := ioutil.ReadFile(file)
+	if err != nil {
+		return []string{file + ": " + err.Error()}
+	}
+
+	schema := make(map[string]reflect.Kind)
+	xmlSchema(reflect.TypeOf(Document{}), "", schema)
+
+	nodes, err := xmlNodes(byteValue)
+	if err != nil {
+		return []string{file + ": " + err.Error()}
+	}
+
+	var unknown []string
+	bad := make(map[string]bool) // raw paths of the values of the wrong type
+	var badPaths []string        // the same paths as reported
+nodes:
+	for _, node := range nodes {
+		raw := node.path
+		node.path = trimXMLIndexes(node.path, schema)
+		// the children of an unknown element are not reported again
+		for _, u := range unknown {
+			if strings.HasPrefix(node.key, u+"/") {
+				continue nodes
+			}
+		}
+		kind, known := schema[node.key]
+		if !known {
+			unknown = append(unknown, node.key)
+			problems = append(problems, node.path+": unknown element or attribute")
+			continue
+		}
+		if problem := xmlCheckKind(kind, node.text); problem != "" {
+			problems = append(problems, node.path+": "+problem)
+			bad[raw] = true
+			badPaths = append(badPaths, node.path)
+		}
+	}
+
+	// values of the wrong type were reported above with their path, they are blanked (read as zero) so
+	// the rest of the document still decodes and every other setting is checked too
+	if len(bad) > 0 {
+		if byteValue, err = xmlBlankValues(byteValue, bad); err != nil {
+			return append(problems, file+": "+err.Error())
+		}
+	}
+
+	var document Document
+	if err := xml.Unmarshal(byteValue, &document); err != nil {
+		return append(problems, file+": "+err.Error())
+	}
+
+semantic:
+	for _, problem := range validateDocument(&document) {
+		// a blanked value would be reported a second time as zero
+		for _, path := range badPaths {
+			if strings.HasPrefix(problem, path+":") {
+				continue semantic
+			}
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// xmlBlankValues rewrites the document with the text of the elements and the attributes at the given
+// paths (as found by xmlNodes) emptied, everything else is copied as it is
+func xmlBlankValues(data []byte, bad map[string]bool) ([]byte, error) {
+	var out bytes.Buffer
+	encoder := xml.NewEncoder(&out)
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	var paths []string
+	counters := []map[string]int{{}}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			line, _ := decoder.InputPos()
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth := len(paths)
+			path := ""
+			if depth > 0 {
+				name := t.Name.Local
+				index := counters[depth][name]
+				counters[depth][name]++
+				path = joinXMLPath(paths[depth-1], fmt.Sprintf("%s[%d]", name, index))
+			}
+			paths = append(paths, path)
+			counters = append(counters, map[string]int{})
+
+			t = t.Copy()
+			for i, attr := range t.Attr {
+				if depth > 0 && bad[path+"/@"+attr.Name.Local] {
+					t.Attr[i].Value = ""
+				}
+			}
+			token = t
+		case xml.CharData:
+			if len(paths) > 0 && bad[paths[len(paths)-1]] {
+				continue
+			}
+		case xml.EndElement:
+			paths = paths[:len(paths)-1]
+			counters = counters[:len(counters)-1]
+		}
+
+		if err := encoder.EncodeToken(xml.CopyToken(token)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// xmlSchema records the kind of every element and attribute the Document struct knows about
+func xmlSchema(t reflect.Type, prefix string, schema map[string]reflect.Kind) {
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("xml")
+		if tag == "" || tag == "-" || field.Name == "XMLName" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		path := name
+		if strings.HasSuffix(tag, ",attr") {
+			path = "@" + name
+		}
+		if prefix != "" {
+			path = prefix + "/" + path
+		}
+
+		kind := field.Type.Kind()
+		if kind == reflect.Slice && field.Type.Elem().Kind() != reflect.Struct {
+			kind = field.Type.Elem().Kind()
+		}
+		schema[path] = kind
+		xmlSchema(field.Type, path, schema)
+	}
+}
+
+// xmlNodes walks the raw xml and returns every element and attribute below the document root in file order
+func xmlNodes(data []byte) ([]xmlNode, error) {
+	var nodes []xmlNode
+	var paths, keys []string
+	var texts []*bytes.Buffer
+	var indexes []int
+	counters := []map[string]int{{}}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			line, _ := decoder.InputPos()
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth := len(paths)
+			if depth == 0 {
+				// the document root itself, it carries the type="talkkonnect/xml" attribute
+				paths, keys = append(paths, ""), append(keys, "")
+				texts, indexes = append(texts, &bytes.Buffer{}), append(indexes, -1)
+				counters = append(counters, map[string]int{})
+				continue
+			}
+
+			name := t.Name.Local
+			index := counters[depth][name]
+			counters[depth][name]++
+
+			path := joinXMLPath(paths[depth-1], fmt.Sprintf("%s[%d]", name, index))
+			key := joinXMLPath(keys[depth-1], name)
+
+			paths, keys = append(paths, path), append(keys, key)
+			texts, indexes = append(texts, &bytes.Buffer{}), append(indexes, len(nodes))
+			counters = append(counters, map[string]int{})
+			nodes = append(nodes, xmlNode{path: path, key: key})
+
+			for _, attr := range t.Attr {
+				nodes = append(nodes, xmlNode{path: path + "/@" + attr.Name.Local, key: key + "/@" + attr.Name.Local, text: attr.Value})
+			}
+		case xml.CharData:
+			if len(texts) > 0 {
+				texts[len(texts)-1].Write(t)
+			}
+		case xml.EndElement:
+			last := len(paths) - 1
+			if indexes[last] >= 0 {
+				nodes[indexes[last]].text = texts[last].String()
+			}
+			paths, keys, texts, indexes = paths[:last], keys[:last], texts[:last], indexes[:last]
+			counters = counters[:len(counters)-1]
+		}
+	}
+
+	return nodes, nil
+}
+
+func joinXMLPath(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "/" + name
+}
+
+// trimXMLIndexes drops the [0] of elements that can only appear once, lists such as account keep their index
+func trimXMLIndexes(path string, schema map[string]reflect.Kind) string {
+	parts := strings.Split(path, "/")
+	key := ""
+	for i, part := range parts {
+		name := part
+		if open := strings.Index(part, "["); open >= 0 {
+			name = part[:open]
+		}
+		key = joinXMLPath(key, name)
+		if strings.HasSuffix(part, "[0]") && schema[key] != reflect.Slice {
+			parts[i] = name
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// xmlCheckKind mirrors what encoding/xml accepts for each kind, empty values are allowed and read as zero
+func xmlCheckKind(kind reflect.Kind, value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+
+	switch kind {
+	case reflect.Bool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Sprintf("%q is not a boolean, use true or false", value)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Sprintf("%q is not a whole number", value)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if _, err := strconv.ParseUint(value, 10, 64); err != nil {
+			return fmt.Sprintf("%q is not a positive whole number", value)
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Sprintf("%q is not a number", value)
+		}
+	}
+	return ""
+}
+
+func validateFileExists(problems []string, path string, file string) []string {
+	if file == "" {
+		return append(problems, path+": no file configured")
+	}
+	if _, err := os.Stat(file); err != nil {
+		return append(problems, path+": file not found")
+	}
+	return problems
+}
+
+func validateOneOf(problems []string, path string, value string, allowed ...string) []string {
+	for _, a := range allowed {
+		if value == a {
+			return problems
+		}
+	}
+	return append(problems, fmt.Sprintf("%s: %q is not one of %s", path, value, strings.Join(allowed, ", ")))
+}
+
+func validateVolume(problems []string, path string, volume float32) []string {
+	if volume < 0 || volume > 1 {
+		return append(problems, fmt.Sprintf("%s: %v is out of range, use 0 to 1", path, volume))
+	}
+	return problems
+}
+
+// validateDocument checks the values that parse fine but that talkkonnect cannot use
+func validateDocument(document *Document) []string {
+	var problems []string
+
+	defaults := 0
+	for i, account := range document.Accounts.Account {
+		path := fmt.Sprintf("accounts/account[%d]", i)
+		if account.Name == "" {
+			problems = append(problems, path+"/@name: account has no name")
+		}
+		if !account.Default {
+			continue
+		}
+		defaults++
+
+		if _, port, err := net.SplitHostPort(account.ServerAndPort); err != nil {
+			problems = append(problems, fmt.Sprintf("%s/serverandport: %q is not of the form host:port", path, account.ServerAndPort))
+		} else if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			problems = append(problems, fmt.Sprintf("%s/serverandport: port %q is not a valid port number", path, port))
+		}
+
+		if account.Certificate != "" {
+			problems = validateFileExists(problems, path+"/certificate", account.Certificate)
+		}
+	}
+	if defaults == 0 {
+		problems = append(problems, "accounts: no account with default=\"true\"")
+	}
+
+	presets := make(map[int]bool)
+	for i, preset := range document.Presets.Preset {
+		path := fmt.Sprintf("presets/preset[%d]", i)
+		if preset.Number < 1 || preset.Number > 99 {
+			problems = append(problems, fmt.Sprintf("%s/@number: %d is not a preset number from 1 to 99", path, preset.Number))
+		} else if presets[preset.Number] {
+			problems = append(problems, fmt.Sprintf("%s/@number: preset %d is defined more than once", path, preset.Number))
+		}
+		presets[preset.Number] = true
+
+		if preset.Account != "" {
+			found := false
+			for _, account := range document.Accounts.Account {
+				if account.Default && account.Name == preset.Account {
+					found = true
+					break
+				}
+			}
+			if !found {
+				problems = append(problems, fmt.Sprintf("%s/account: %q is not the name of a default account", path, preset.Account))
+			}
+		}
+	}
+
+	settings := document.Global.Software.Settings
+	if settings.Loglevel != "" {
+		problems = validateOneOf(problems, "global/software/settings/loglevel", settings.Loglevel, "trace", "debug", "info", "warning", "error", "alert")
+	}
+	if settings.Logging != "" {
+		problems = validateOneOf(problems, "global/software/settings/logging", settings.Logging, "screen", "screenwithlineno", "screenandfile", "screenandfilewithlineno")
+	}
+	if settings.NextServerIndex < 0 || (defaults > 0 && settings.NextServerIndex >= defaults) {
+		problems = append(problems, fmt.Sprintf("global/software/settings/nextserverindex: %d is out of range, %d default account(s) found", settings.NextServerIndex, defaults))
+	}
+
+	autoProvisioning := document.Global.Software.AutoProvisioning
+	if autoProvisioning.Enabled && autoProvisioning.URL == "" {
+		problems = append(problems, "global/software/autoprovisioning/url: autoprovisioning is enabled but no url is configured")
+	}
+
+	reconnect := document.Global.Software.Reconnect
+	if reconnect.InitialDelaySecs < 0 {
+		problems = append(problems, "global/software/reconnect/initialdelaysecs: must not be negative")
+	}
+	if reconnect.MaxDelaySecs != 0 && reconnect.MaxDelaySecs < reconnect.InitialDelaySecs {
+		problems = append(problems, "global/software/reconnect/maxdelaysecs: must not be less than initialdelaysecs")
+	}
+	if reconnect.Multiplier != 0 && reconnect.Multiplier < 1 {
+		problems = append(problems, fmt.Sprintf("global/software/reconnect/multiplier: %v must be at least 1", reconnect.Multiplier))
+	}
+	if reconnect.Jitter < 0 || reconnect.Jitter > 1 {
+		problems = append(problems, fmt.Sprintf("global/software/reconnect/jitter: %v is not between 0 and 1", reconnect.Jitter))
+	}
+	if reconnect.FailoverAfter < 0 {
+		problems = append(problems, "global/software/reconnect/failoverafter: must not be negative")
+	}
+
+	health := document.Global.Software.Health
+	if health.Enabled {
+		if health.IntervalSecs < 0 || health.TimeoutSecs < 0 || health.MaxLatencyMs < 0 || health.FailureThreshold < 0 || health.ReturnAfter < 0 {
+			problems = append(problems, "global/software/health: intervalsecs, timeoutsecs, maxlatencyms, failurethreshold and returnafter must not be negative")
+		}
+		if health.IntervalSecs > 0 && health.TimeoutSecs >= health.IntervalSecs {
+			problems = append(problems, "global/software/health/timeoutsecs: must be less than intervalsecs")
+		}
+		if health.PreferredAccount != "" {
+			found := false
+			for _, account := range document.Accounts.Account {
+				if account.Default && account.Name == health.PreferredAccount {
+					found = true
+				}
+			}
+			if !found {
+				problems = append(problems, fmt.Sprintf("global/software/health/preferredaccount: %q is not the name of a default account", health.PreferredAccount))
+			}
+		}
+	}
+
+	sounds := document.Global.Software.Sounds
+	if sounds.Event.Enabled {
+		problems = validateFileExists(problems, "global/software/sounds/event/joinedfilenameandpath", sounds.Event.JoinedFilenameAndPath)
+		problems = validateFileExists(problems, "global/software/sounds/event/leftfilenameandpath", sounds.Event.LeftFilenameAndPath)
+		problems = validateFileExists(problems, "global/software/sounds/event/messagefilenameandpath", sounds.Event.MessageFilenameAndPath)
+	}
+	if sounds.Alert.Enabled {
+		problems = validateFileExists(problems, "global/software/sounds/alert/filenameandpath", sounds.Alert.FilenameAndPath)
+		problems = validateVolume(problems, "global/software/sounds/alert/volume", sounds.Alert.Volume)
+	}
+	if sounds.IncommingBeep.Enabled {
+		problems = validateFileExists(problems, "global/software/sounds/incommingbeep/filenameandpath", sounds.IncommingBeep.FilenameAndPath)
+		problems = validateVolume(problems, "global/software/sounds/incommingbeep/volume", sounds.IncommingBeep.Volume)
+	}
+	if sounds.RogerBeep.Enabled {
+		problems = validateFileExists(problems, "global/software/sounds/rogerbeep/filenameandpath", sounds.RogerBeep.FilenameAndPath)
+		problems = validateVolume(problems, "global/software/sounds/rogerbeep/volume", sounds.RogerBeep.Volume)
+	}
+	if sounds.RepeaterTone.Enabled {
+		if sounds.RepeaterTone.ToneFrequencyHz <= 0 {
+			problems = append(problems, "global/software/sounds/repeatertone/tonefrequencyhz: must be greater than 0")
+		}
+		if sounds.RepeaterTone.ToneDurationSec <= 0 {
+			problems = append(problems, "global/software/sounds/repeatertone/tonedurationsec: must be greater than 0")
+		}
+	}
+	if sounds.Stream.Enabled {
+		// the stream may be a network url handed to ffmpeg, only local files can be checked
+		if !strings.Contains(sounds.Stream.FilenameAndPath, "://") {
+			problems = validateFileExists(problems, "global/software/sounds/stream/filenameandpath", sounds.Stream.FilenameAndPath)
+		}
+		problems = validateVolume(problems, "global/software/sounds/stream/volume", sounds.Stream.Volume)
+	}
+
+	audio := document.Global.Software.Audio
+	if audio.Backend != "" {
+		problems = validateOneOf(problems, "global/software/audio/backend", strings.ToLower(strings.TrimSpace(audio.Backend)), "openal", "file", "null")
+	}
+	if strings.EqualFold(strings.TrimSpace(audio.Backend), "file") && audio.CaptureFile != "" {
+		problems = validateFileExists(problems, "global/software/audio/capturefile", audio.CaptureFile)
+	}
+	for i, user := range audio.Mixer.User {
+		path := fmt.Sprintf("global/software/audio/mixer/user[%d]", i)
+		if strings.TrimSpace(user.Name) == "" {
+			problems = append(problems, path+"/@name: user has no name")
+		}
+		if user.Gain == nil {
+			problems = append(problems, path+"/@gain: no gain configured")
+		} else if *user.Gain < 0 || *user.Gain > 4 {
+			problems = append(problems, fmt.Sprintf("%s/@gain: %v is out of range, use 0 to 4", path, *user.Gain))
+		}
+	}
+
+	channelNavigation := document.Global.Software.ChannelNavigation
+	for i, pattern := range channelNavigation.Include {
+		if _, err := path.Match(strings.TrimSpace(pattern), ""); err != nil {
+			problems = append(problems, fmt.Sprintf("global/software/channelnavigation/include[%d]: %q is not a valid pattern", i, pattern))
+		}
+	}
+	for i, pattern := range channelNavigation.Exclude {
+		if _, err := path.Match(strings.TrimSpace(pattern), ""); err != nil {
+			problems = append(problems, fmt.Sprintf("global/software/channelnavigation/exclude[%d]: %q is not a valid pattern", i, pattern))
+		}
+	}
+
+	scan := document.Global.Software.Scan
+	if scan.DwellMs < 0 || scan.HangSecs < 0 || scan.PriorityIntervalSecs < 0 {
+		problems = append(problems, "global/software/scan: dwellms, hangsecs and priorityintervalsecs must not be negative")
+	}
+
+	calls := document.Global.Software.Calls
+	if calls.IdleTimeoutSecs != nil && *calls.IdleTimeoutSecs < 0 {
+		problems = append(problems, "global/software/calls/idletimeoutsecs: must not be negative")
+	}
+	callNames := make(map[string]bool)
+	for i, call := range calls.Call {
+		path := fmt.Sprintf("global/software/calls/call[%d]", i)
+		name := strings.ToLower(strings.TrimSpace(call.Name))
+		if name == "" {
+			problems = append(problems, path+": the name attribute is missing")
+		} else if callNames[name] {
+			problems = append(problems, fmt.Sprintf("%s: name %q is used more than once", path, call.Name))
+		} else if strings.Contains(name, ":") {
+			problems = append(problems, fmt.Sprintf("%s: name %q must not contain a colon", path, call.Name))
+		}
+		callNames[name] = true
+		if len(call.User) == 0 && len(call.Channel) == 0 {
+			problems = append(problems, path+": needs at least one user or channel to call")
+		}
+	}
+
+	smtpSection := document.Global.Software.SMTP
+	if smtpSection.Enabled {
+		if smtpSection.Server != "" {
+			if _, _, err := net.SplitHostPort(strings.TrimSpace(smtpSection.Server)); err != nil {
+				problems = append(problems, fmt.Sprintf("global/software/smtp/server: %q must be host:port", smtpSection.Server))
+			}
+		}
+		if smtpSection.Username == "" || smtpSection.Receiver == "" {
+			problems = append(problems, "global/software/smtp: username and receiver are needed to send email")
+		}
+	}
+
+	gps := document.Global.Hardware.GPS
+	if gps.Enabled {
+		if gps.File != "" {
+			problems = validateFileExists(problems, "global/hardware/gps/file", gps.File)
+		}
+		if gps.Parity != "" {
+			problems = validateOneOf(problems, "global/hardware/gps/parity", strings.ToLower(strings.TrimSpace(gps.Parity)), "none", "even", "odd")
+		}
+		if gps.ReportMode != "" {
+			problems = validateOneOf(problems, "global/hardware/gps/reportmode", strings.ToLower(strings.TrimSpace(gps.ReportMode)), "none", "comment", "message")
+		}
+		if gps.StopBits < 0 || gps.StopBits > 2 {
+			problems = append(problems, fmt.Sprintf("global/hardware/gps/stopbits: %d must be 1 or 2", gps.StopBits))
+		}
+		if gps.DataBits != 0 && (gps.DataBits < 5 || gps.DataBits > 8) {
+			problems = append(problems, fmt.Sprintf("global/hardware/gps/databits: %d must be 5 to 8", gps.DataBits))
+		}
+		if gps.Baud < 0 || gps.ReplayDelayMs < 0 || gps.ReportIntervalSecs < 0 || gps.ReportMinMoveMeters < 0 || gps.ReportMaxIntervalSecs < 0 {
+			problems = append(problems, "global/hardware/gps: baud, replaydelayms and the report settings must not be negative")
+		}
+	}
+
+	panicFunction := document.Global.Hardware.PanicFunction
+	if panicFunction.Enabled {
+		if panicFunction.FilenameAndPath != "" {
+			problems = validateFileExists(problems, "global/hardware/panicfunction/filenameandpath", panicFunction.FilenameAndPath)
+		}
+		if panicFunction.RepeatSecs < 0 || panicFunction.TxLock.TimeOutSecs < 0 {
+			problems = append(problems, "global/hardware/panicfunction: repeatsecs and txlocktimeoutsecs must not be negative")
+		}
+		if panicFunction.PMailEnabled && !smtpSection.Enabled {
+			problems = append(problems, "global/hardware/panicfunction/panicemail: needs the smtp section to be enabled")
+		}
+	}
+
+	beacon := document.Global.Software.MQTTBeacon
+	if beacon.Enabled {
+		if !document.Global.Software.MQTT.MQTTEnabled {
+			problems = append(problems, "global/software/mqttbeacon: needs the mqtt section to be enabled")
+		}
+		if strings.ContainsAny(beacon.Topic, "+#") {
+			problems = append(problems, fmt.Sprintf("global/software/mqttbeacon/topic: %q must not contain the + or # wildcards", beacon.Topic))
+		}
+		smart := beacon.SmartBeaconing
+		if beacon.IntervalSecs < 0 || smart.SlowIntervalSecs < 0 || smart.FastIntervalSecs < 0 || smart.MinTurnSecs < 0 || smart.SlowSpeedKmh < 0 || smart.FastSpeedKmh < 0 || smart.MinTurnAngle < 0 || smart.TurnSlope < 0 {
+			problems = append(problems, "global/software/mqttbeacon: intervals, speeds and turn settings must not be negative")
+		}
+		if smart.Enabled && smart.FastSpeedKmh != 0 && smart.FastSpeedKmh <= smart.SlowSpeedKmh {
+			problems = append(problems, "global/software/mqttbeacon/smartbeaconing: fastspeedkmh must be above slowspeedkmh")
+		}
+	}
+
+	homeAssistant := document.Global.Software.HomeAssistant
+	if homeAssistant.Enabled {
+		if !document.Global.Software.MQTT.MQTTEnabled {
+			problems = append(problems, "global/software/homeassistant: needs the mqtt section to be enabled")
+		}
+		if strings.ContainsAny(homeAssistant.DiscoveryPrefix, "+#") {
+			problems = append(problems, fmt.Sprintf("global/software/homeassistant/discoveryprefix: %q must not contain the + or # wildcards", homeAssistant.DiscoveryPrefix))
+		}
+	}
+
+	textMessages := document.Global.Software.TextMessages
+	if textMessages.HistorySize != nil && *textMessages.HistorySize < 0 {
+		problems = append(problems, "global/software/textmessages/historysize: must not be negative")
+	}
+
+	monitor := document.Global.Software.Monitor
+	if monitor.Enabled && len(monitor.Channel) == 0 {
+		problems = append(problems, "global/software/monitor: monitoring is enabled but no channel is configured")
+	}
+
+	vox := document.Global.Software.Vox
+	if vox.Enabled {
+		if vox.ThresholdDB >= 0 {
+			problems = append(problems, fmt.Sprintf("global/software/vox/thresholddb: %v must be below 0 dBFS", vox.ThresholdDB))
+		}
+		if vox.HysteresisDB < 0 {
+			problems = append(problems, "global/software/vox/hysteresisdb: must not be negative")
+		}
+		if vox.AttackMs < 0 || vox.HangMs < 0 || vox.PreRollMs < 0 {
+			problems = append(problems, "global/software/vox: attackms, hangms and prerollms must not be negative")
+		}
+	}
+
+	audioRecord := document.Global.Software.AudioRecordFunction
+	if audioRecord.Enabled {
+		if audioRecord.RecordMode != "" {
+			problems = validateOneOf(problems, "global/software/audiorecordfunction/recordmode", audioRecord.RecordMode, "traffic", "mic", "combo")
+		}
+		if audioRecord.RecordMaxFiles < 0 || audioRecord.RecordArchiveKeep < 0 {
+			problems = append(problems, "global/software/audiorecordfunction: recordmaxfiles and recordarchivekeep must not be negative")
+		}
+	}
+
+	txTimeOut := document.Global.Software.TxTimeOut
+	if txTimeOut.Enabled && txTimeOut.TxTimeOutSecs <= 0 {
+		problems = append(problems, "global/software/txtimeout/txtimeoutsecs: must be greater than 0 when txtimeout is enabled")
+	}
+	if txTimeOut.LockoutSecs < 0 {
+		problems = append(problems, "global/software/txtimeout/lockoutsecs: must not be negative")
+	}
+
+	api := document.Global.Software.API
+	if api.Enabled {
+		if port, err := strconv.Atoi(api.ListenPort); err != nil || port < 1 || port > 65535 {
+			problems = append(problems, fmt.Sprintf("global/software/api/apilistenport: %q is not a valid port number", api.ListenPort))
+		}
+	}
+
+	mqtt := document.Global.Software.MQTT
+	if mqtt.MQTTEnabled {
+		if mqtt.MQTTBroker == "" {
+			problems = append(problems, "global/software/mqtt/mqttbroker: mqtt is enabled but no broker is configured")
+		} else if !strings.Contains(mqtt.MQTTBroker, "://") {
+			problems = append(problems, fmt.Sprintf("global/software/mqtt/mqttbroker: %q has no scheme, use tcp://, ssl:// or ws://", mqtt.MQTTBroker))
+		}
+		if mqtt.MQTTTopic == "" {
+			problems = append(problems, "global/software/mqtt/mqtttopic: mqtt is enabled but no topic is configured")
+		}
+		if mqtt.MQTTQos < 0 || mqtt.MQTTQos > 2 {
+			problems = append(problems, fmt.Sprintf("global/software/mqtt/qos: %d is out of range, use 0, 1 or 2", mqtt.MQTTQos))
+		}
+	}
+
+	if document.Global.Hardware.TargetBoard != "" {
+		problems = validateOneOf(problems, "global/hardware/@targetboard", document.Global.Hardware.TargetBoard, "pc", "rpi")
+	}
+
+	return problems
+}